@@ -0,0 +1,231 @@
+package octokey
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/octokey/octokey-go/buffer"
+	"io"
+	"net"
+	"os"
+	"sync"
+)
+
+// An Agent signs auth requests with keys held by an ssh-agent, so that the
+// private keys never leave the agent. It speaks the ssh-agent protocol from
+// draft-miller-ssh-agent over any connection.
+type Agent struct {
+	conn  io.ReadWriter
+	mutex sync.Mutex
+}
+
+// An AgentIdentity is a key held by an ssh-agent.
+type AgentIdentity struct {
+	Key     Key
+	Comment string
+}
+
+// Message numbers and flags from the ssh-agent protocol
+const (
+	SSH_AGENT_FAILURE             = 5
+	SSH_AGENTC_REQUEST_IDENTITIES = 11
+	SSH_AGENT_IDENTITIES_ANSWER   = 12
+	SSH_AGENTC_SIGN_REQUEST       = 13
+	SSH_AGENT_SIGN_RESPONSE       = 14
+
+	SSH_AGENT_RSA_SHA2_256 = 2
+	SSH_AGENT_RSA_SHA2_512 = 4
+)
+
+// The largest message that will be read from an ssh-agent (bytes)
+const AGENT_MAX_MESSAGE_SIZE = 256 * 1024
+
+var (
+	ErrAgentNoSocket = errors.New("octokey/agent: SSH_AUTH_SOCK is not set")
+	ErrAgentFailure  = errors.New("octokey/agent: agent refused request")
+	ErrAgentResponse = errors.New("octokey/agent: invalid response from agent")
+)
+
+// NewAgent returns an Agent that talks to an ssh-agent over the connection.
+func NewAgent(conn io.ReadWriter) *Agent {
+	return &Agent{conn: conn}
+}
+
+// DialAgent connects to the ssh-agent listening on SSH_AUTH_SOCK. Call Close
+// when you have finished with it.
+func DialAgent() (*Agent, error) {
+
+	path := os.Getenv("SSH_AUTH_SOCK")
+	if path == "" {
+		return nil, ErrAgentNoSocket
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewAgent(conn), nil
+}
+
+// Close closes the connection to the agent, if it can be closed.
+func (a *Agent) Close() error {
+	if c, ok := a.conn.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Identities returns the keys held by the agent. Keys of unsupported types
+// are skipped.
+func (a *Agent) Identities() ([]*AgentIdentity, error) {
+
+	request := new(buffer.Buffer)
+	request.AddUint8(SSH_AGENTC_REQUEST_IDENTITIES)
+
+	response, err := a.call(request, SSH_AGENT_IDENTITIES_ANSWER)
+	if err != nil {
+		return nil, err
+	}
+
+	count := response.ScanUint32()
+	identities := []*AgentIdentity{}
+
+	for i := uint32(0); i < count && response.Error == nil; i++ {
+		keyBuffer := response.ScanBuffer()
+		comment := response.ScanString()
+
+		key, err := ReadKey(keyBuffer)
+		keyBuffer.ScanEof()
+
+		if err == nil && keyBuffer.Error == nil {
+			identities = append(identities, &AgentIdentity{key, comment})
+		}
+	}
+
+	response.ScanEof()
+
+	if response.Error != nil {
+		return nil, malformed(ErrAgentResponse, response.Error)
+	}
+
+	return identities, nil
+}
+
+// Signer returns a Signer that signs auth requests for the user with one of
+// the agent's keys.
+func (a *Agent) Signer(username string, key Key) Signer {
+	return &agentSigner{agent: a, username: username, key: key}
+}
+
+// Sign asks the agent to sign the data with the key, using the signing
+// algorithm. It returns the signature without the algorithm name.
+func (a *Agent) Sign(key Key, algorithm string, data []byte) ([]byte, error) {
+
+	signing, ok := signingAlgorithms[algorithm]
+	if !ok || signing.keyType != key.Type() {
+		return nil, ErrKeySignatureAlgorithm
+	}
+
+	var flags uint32
+	switch algorithm {
+	case SIGNING_ALGORITHM_RSA_SHA2_256:
+		flags = SSH_AGENT_RSA_SHA2_256
+	case SIGNING_ALGORITHM_RSA_SHA2_512:
+		flags = SSH_AGENT_RSA_SHA2_512
+	}
+
+	request := new(buffer.Buffer)
+	request.AddUint8(SSH_AGENTC_SIGN_REQUEST)
+	request.AddBuffer(keyBuffer(key))
+	request.AddVarBytes(data)
+	request.AddUint32(flags)
+
+	response, err := a.call(request, SSH_AGENT_SIGN_RESPONSE)
+	if err != nil {
+		return nil, err
+	}
+
+	signature := response.ScanBuffer()
+	response.ScanEof()
+	signed := signature.ScanString()
+	sig := signature.ScanVarBytes()
+	signature.ScanEof()
+
+	if response.Error != nil {
+		return nil, malformed(ErrAgentResponse, response.Error)
+	}
+
+	if signature.Error != nil {
+		return nil, malformed(ErrAgentResponse, signature.Error)
+	}
+
+	if signed != algorithm {
+		return nil, ErrAgentResponse
+	}
+
+	return sig, nil
+}
+
+// call sends a request to the agent and reads the response, which must be
+// of the expected type. The type is removed from the response.
+func (a *Agent) call(request *buffer.Buffer, expected uint8) (*buffer.Buffer, error) {
+
+	if request.Error != nil {
+		return nil, request.Error
+	}
+
+	message := new(buffer.Buffer)
+	message.AddBuffer(request)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if _, err := a.conn.Write(message.Raw()); err != nil {
+		return nil, err
+	}
+
+	var length uint32
+	if err := binary.Read(a.conn, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+
+	if length == 0 || length > AGENT_MAX_MESSAGE_SIZE {
+		return nil, ErrAgentResponse
+	}
+
+	content := make([]byte, length)
+	if _, err := io.ReadFull(a.conn, content); err != nil {
+		return nil, err
+	}
+
+	response := &buffer.Buffer{Buffer: *bytes.NewBuffer(content)}
+
+	switch response.ScanUint8() {
+	case expected:
+		return response, nil
+	case SSH_AGENT_FAILURE:
+		return nil, ErrAgentFailure
+	}
+
+	return nil, ErrAgentResponse
+}
+
+// An agentSigner is a Signer that uses a key held by an ssh-agent.
+type agentSigner struct {
+	agent    *Agent
+	username string
+	key      Key
+}
+
+func (s *agentSigner) PublicKey() Key {
+	return s.key
+}
+
+func (s *agentSigner) Username() string {
+	return s.username
+}
+
+func (s *agentSigner) Sign(algorithm string, data []byte) ([]byte, error) {
+	return s.agent.Sign(s.key, algorithm, data)
+}
@@ -0,0 +1,192 @@
+package octokey
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"github.com/octokey/octokey-go/buffer"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+// testAgent is a minimal ssh-agent that holds some Signers.
+type testAgent struct {
+	signers []Signer
+}
+
+func (agent *testAgent) serve(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		var length uint32
+		if binary.Read(conn, binary.BigEndian, &length) != nil {
+			return
+		}
+		content := make([]byte, length)
+		if _, err := io.ReadFull(conn, content); err != nil {
+			return
+		}
+
+		request := &buffer.Buffer{Buffer: *bytes.NewBuffer(content)}
+		response := agent.handle(request)
+
+		message := new(buffer.Buffer)
+		message.AddBuffer(response)
+		conn.Write(message.Raw())
+	}
+}
+
+func (agent *testAgent) handle(request *buffer.Buffer) *buffer.Buffer {
+
+	response := new(buffer.Buffer)
+
+	switch request.ScanUint8() {
+	case SSH_AGENTC_REQUEST_IDENTITIES:
+		response.AddUint8(SSH_AGENT_IDENTITIES_ANSWER)
+		response.AddUint32(uint32(len(agent.signers) + 1))
+		for _, s := range agent.signers {
+			response.AddBuffer(keyBuffer(s.PublicKey()))
+			response.AddString("key " + s.PublicKey().Type())
+		}
+		dss := new(buffer.Buffer)
+		dss.AddString("ssh-dss")
+		response.AddBuffer(dss)
+		response.AddString("unsupported")
+		return response
+
+	case SSH_AGENTC_SIGN_REQUEST:
+		blob := request.ScanVarBytes()
+		data := request.ScanVarBytes()
+		flags := request.ScanUint32()
+
+		for _, s := range agent.signers {
+			if request.Error != nil || !bytes.Equal(blob, keyBuffer(s.PublicKey()).Raw()) {
+				continue
+			}
+			algorithm := signingAlgorithmFor(s.PublicKey(), SIGNING_ALGORITHM_SSH_RSA)
+			if flags&SSH_AGENT_RSA_SHA2_256 != 0 {
+				algorithm = signingAlgorithmFor(s.PublicKey(), SIGNING_ALGORITHM_RSA_SHA2_256)
+			} else if flags&SSH_AGENT_RSA_SHA2_512 != 0 {
+				algorithm = signingAlgorithmFor(s.PublicKey(), SIGNING_ALGORITHM_RSA_SHA2_512)
+			}
+			sig, err := s.Sign(algorithm, data)
+			if err != nil {
+				break
+			}
+			signature := new(buffer.Buffer)
+			signature.AddString(algorithm)
+			signature.AddVarBytes(sig)
+			response.AddUint8(SSH_AGENT_SIGN_RESPONSE)
+			response.AddBuffer(signature)
+			return response
+		}
+	}
+
+	response.AddUint8(SSH_AGENT_FAILURE)
+	return response
+}
+
+func newTestAgent(t *testing.T) *testAgent {
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	agent := &testAgent{}
+	for _, key := range []crypto.Signer{rsaKey, ed25519Key, ecdsaKey} {
+		s, err := NewSigner("agent", key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		agent.signers = append(agent.signers, s)
+	}
+
+	return agent
+}
+
+func TestAgent(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	server := newTestAgent(t)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+
+	t.Setenv("SSH_AUTH_SOCK", "")
+	if _, err := DialAgent(); err != ErrAgentNoSocket {
+		t.Error("expected ErrAgentNoSocket, got", err)
+	}
+
+	t.Setenv("SSH_AUTH_SOCK", path)
+	agent, err := DialAgent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Close()
+
+	identities, err := agent.Identities()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(identities) != len(server.signers) {
+		t.Fatal("expected", len(server.signers), "identities, got", len(identities))
+	}
+
+	clientIp := net.ParseIP("127.0.0.1")
+	O := &Octokey{ChallengeSecret: []byte("hello world"), SigningAlgorithm: SIGNING_ALGORITHM_RSA_SHA2_512}
+
+	for i, identity := range identities {
+		if !identity.Key.Equal(server.signers[i].PublicKey()) || identity.Comment != "key "+identity.Key.Type() {
+			t.Error("wrong identity", identity)
+		}
+
+		c, err := O.NewChallenge(clientIp)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s, err := O.SignChallenge(c, "https://example.com/login", agent.Signer("conrad", identity.Key))
+		if err != nil {
+			t.Fatal(identity.Key.Type(), err)
+		}
+
+		username, key, err := O.VerifyAuthRequest(s, "https://example.com/login", clientIp)
+		if err != nil || username != "conrad" || !key.Equal(identity.Key) {
+			t.Error(identity.Key.Type(), username, err)
+		}
+	}
+
+	unknown := newTestSigner(t, "conrad").PublicKey()
+	if _, err := agent.Sign(unknown, SIGNING_ALGORITHM_RSA_SHA2_256, []byte("data")); err != ErrAgentFailure {
+		t.Error("expected ErrAgentFailure, got", err)
+	}
+}
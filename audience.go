@@ -0,0 +1,86 @@
+package octokey
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrOriginFormat   = errors.New("octokey/audience: invalid origin")
+	ErrPolicyAudience = errors.New("octokey/policy: invalid Audience")
+)
+
+// NormalizeOrigin returns the origin of a url as "scheme://host[:port]", with
+// the scheme and host in lower case and default ports removed.
+func NormalizeOrigin(s string) (string, error) {
+
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Host == "" || u.Opaque != "" {
+		return "", ErrOriginFormat
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+
+	if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		port = ""
+	}
+
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	if port != "" {
+		host += ":" + port
+	}
+
+	return scheme + "://" + host, nil
+}
+
+// isOrigin returns true if the audience is an origin rather than a service id.
+func isOrigin(audience string) bool {
+	return strings.Contains(audience, "://")
+}
+
+// checkAudience returns an error if the Audience looks like an origin, but
+// can't be parsed as one.
+func (O *Octokey) checkAudience() error {
+
+	if isOrigin(O.Audience) {
+		if _, err := NormalizeOrigin(O.Audience); err != nil {
+			return ErrPolicyAudience
+		}
+	}
+
+	return nil
+}
+
+// audience returns the normalized Audience.
+func (O *Octokey) audience() string {
+
+	if isOrigin(O.Audience) {
+		origin, err := NormalizeOrigin(O.Audience)
+		if err == nil {
+			return origin
+		}
+	}
+
+	return O.Audience
+}
+
+// originMatches returns true if the request url is allowed by the Audience.
+func (O *Octokey) originMatches(requestUrl string) bool {
+
+	if !isOrigin(O.Audience) {
+		return true
+	}
+
+	origin, err := NormalizeOrigin(requestUrl)
+	if err != nil {
+		return false
+	}
+
+	return origin == O.audience()
+}
@@ -0,0 +1,90 @@
+package octokey
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestNormalizeOrigin(t *testing.T) {
+
+	for input, expected := range map[string]string{
+		"https://example.com":               "https://example.com",
+		"HTTPS://Example.COM:443/login?x=1": "https://example.com",
+		"http://example.com:80/":            "http://example.com",
+		"http://example.com:8080/":          "http://example.com:8080",
+		"https://[2001:DB8::1]:443/":        "https://[2001:db8::1]",
+		"https://[2001:db8::1]:8443/":       "https://[2001:db8::1]:8443",
+		"example.com":                       "",
+		"mailto:conrad@example.com":         "",
+	} {
+		actual, err := NormalizeOrigin(input)
+		if actual != expected || (expected == "") != (err == ErrOriginFormat) {
+			t.Error(input, actual, "!=", expected, err)
+		}
+	}
+}
+
+func TestAudience(t *testing.T) {
+
+	secret := []byte("hello world")
+	clientIp := net.ParseIP("127.0.0.1")
+	signer := newTestSigner(t, "conrad")
+
+	admin := &Octokey{ChallengeSecret: secret, Audience: "https://admin.example.com"}
+	billing := &Octokey{ChallengeSecret: secret, Audience: "https://billing.example.com"}
+	batch := &Octokey{ChallengeSecret: secret, Audience: "batch-tools"}
+
+	c, err := admin.NewChallenge(clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := billing.ValidateChallenge(c, clientIp); err == nil {
+		t.Error("challenge valid for another audience")
+	}
+	if err := batch.ValidateChallenge(c, clientIp); err == nil {
+		t.Error("challenge valid for another service")
+	}
+
+	tests := []struct {
+		O          *Octokey
+		requestUrl string
+		err        error
+		comment    string
+	}{
+		{admin, "https://admin.example.com/login", nil, "same origin"},
+		{admin, "https://ADMIN.example.com:443/login", nil, "same origin, not normalized"},
+		{admin, "https://billing.example.com/login", ErrAuthRequestOriginMismatch, "other origin"},
+		{admin, "http://admin.example.com/login", ErrAuthRequestOriginMismatch, "other scheme"},
+		{billing, "https://billing.example.com/login", ErrChallengeHmacMismatch, "challenge from other audience"},
+	}
+
+	for _, test := range tests {
+		s, err := admin.SignChallenge(c, test.requestUrl, signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, _, err = test.O.VerifyAuthRequest(s, test.requestUrl, clientIp)
+		if test.err == nil && err != nil || test.err != nil && !errors.Is(err, test.err) {
+			t.Error(test.comment, err)
+		}
+	}
+
+	c, err = batch.NewChallenge(clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := batch.SignChallenge(c, "cli://anything", signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := batch.VerifyAuthRequest(s, "cli://anything", clientIp); err != nil {
+		t.Error("service id audience checked the request url", err)
+	}
+
+	if err := (&Octokey{Audience: "https://"}).CheckPolicy(); err != ErrPolicyAudience {
+		t.Error("expected ErrPolicyAudience, got", err)
+	}
+}
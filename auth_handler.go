@@ -0,0 +1,223 @@
+package octokey
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// An AuthHandler protects an http.Handler with Octokey logins.
+//
+// Clients fetch a challenge (either from ChallengePath, or from the
+// WWW-Authenticate header of a 401 response), sign it with SignChallenge, and
+// send the resulting auth request either in an "Authorization: Octokey <auth
+// request>" header or as the "auth_request" field of a POSTed form.
+//
+// Once the auth request has been verified, and the KeyStore allows the public
+// key to log in as the user, the request is passed on to Handler with the
+// username in its context. Use UsernameFromContext to read it.
+type AuthHandler struct {
+	O       *Octokey
+	Keys    KeyStore
+	Handler http.Handler
+
+	// ChallengePath is the path from which clients can GET a new challenge.
+	// If it is empty, challenges are only sent with 401 responses.
+	ChallengePath string
+
+	// BaseUrl is the scheme and host that clients use to reach the server,
+	// for example "https://example.com". Clients should sign BaseUrl
+	// followed by the path and query of the request. If it is empty, the
+	// Audience of O is used when it is an origin.
+	//
+	// The Host header and TLS state of the request are never used, because
+	// they are chosen by the client.
+	BaseUrl string
+
+	// RequestUrl returns the url that clients should have signed for this
+	// request. If it is set, BaseUrl is ignored. One of RequestUrl, BaseUrl
+	// or an origin Audience is required, otherwise every request is
+	// rejected with a 500.
+	RequestUrl func(r *http.Request) string
+
+	// TrustedProxies are the proxies whose Forwarded and X-Forwarded-For
+	// headers are used to find the client's IP address. See ClientIP.
+	TrustedProxies []*net.IPNet
+
+	// ChannelBinding binds challenges and auth requests to the TLS connection
+	// that they were sent over, see ChannelBinding. Clients must use the same
+	// connection to fetch the challenge and send the auth request, and plain
+	// HTTP requests are rejected.
+	ChannelBinding bool
+}
+
+type contextKey int
+
+const usernameContextKey contextKey = 0
+
+const (
+	AUTH_SCHEME     = "Octokey"
+	AUTH_FORM_FIELD = "auth_request"
+)
+
+var (
+	ErrAuthHandlerRequestUrl = errors.New("octokey/auth_handler: BaseUrl, RequestUrl or an origin Audience is required")
+)
+
+// NewAuthHandler creates an AuthHandler that allows through requests from users
+// who have logged in with one of their keys in the KeyStore.
+func (O *Octokey) NewAuthHandler(keys KeyStore, h http.Handler) *AuthHandler {
+	return &AuthHandler{O: O, Keys: keys, Handler: h}
+}
+
+// UsernameFromContext returns the username set by an AuthHandler.
+func UsernameFromContext(ctx context.Context) (string, bool) {
+	username, ok := ctx.Value(usernameContextKey).(string)
+	return username, ok
+}
+
+func (h *AuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	requestUrl, err := h.requestUrl(r)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	clientIp := ClientIP(r, h.TrustedProxies)
+	if clientIp == nil {
+		http.Error(w, "400 bad request", http.StatusBadRequest)
+		return
+	}
+
+	var binding []byte
+	if h.ChannelBinding {
+		binding, err = ChannelBinding(r.TLS)
+		if err != nil {
+			http.Error(w, "400 bad request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if h.ChallengePath != "" && r.URL.Path == h.ChallengePath {
+		h.serveChallenge(w, r, clientIp, binding)
+		return
+	}
+
+	s := authRequestFrom(r)
+	if s == "" {
+		h.unauthorized(w, clientIp, binding)
+		return
+	}
+
+	username, key, err := h.O.VerifyBoundAuthRequest(s, requestUrl, clientIp, binding)
+	if errors.Is(err, ErrRateLimited) {
+		h.serverError(w, err)
+		return
+	}
+	if err != nil {
+		h.unauthorized(w, clientIp, binding)
+		return
+	}
+
+	ok, err := h.O.Authorize(h.Keys, clientIp, username, key)
+	if err != nil {
+		http.Error(w, "500 server error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		h.unauthorized(w, clientIp, binding)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), usernameContextKey, username)
+	h.Handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// serveChallenge responds with a new challenge in plain text.
+func (h *AuthHandler) serveChallenge(w http.ResponseWriter, r *http.Request, clientIp net.IP, binding []byte) {
+
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	c, err := h.O.NewBoundChallenge(clientIp, binding)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(c))
+}
+
+// unauthorized responds with a 401 including a new challenge that the client
+// can sign to retry the request.
+func (h *AuthHandler) unauthorized(w http.ResponseWriter, clientIp net.IP, binding []byte) {
+
+	c, err := h.O.NewBoundChallenge(clientIp, binding)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("WWW-Authenticate", AUTH_SCHEME+` challenge="`+c+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+	http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+}
+
+// serverError responds to an error from the Octokey, with a 429 if the client
+// has been rate limited.
+func (h *AuthHandler) serverError(w http.ResponseWriter, err error) {
+
+	var limited *RateLimitError
+	if errors.As(err, &limited) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+		http.Error(w, "429 too many requests", http.StatusTooManyRequests)
+		return
+	}
+
+	http.Error(w, "500 server error", http.StatusInternalServerError)
+}
+
+// requestUrl returns the url that the client should have signed.
+func (h *AuthHandler) requestUrl(r *http.Request) (string, error) {
+
+	if h.RequestUrl != nil {
+		return h.RequestUrl(r), nil
+	}
+
+	base := h.BaseUrl
+	if base == "" && isOrigin(h.O.Audience) {
+		base = h.O.audience()
+	}
+
+	if base == "" {
+		return "", ErrAuthHandlerRequestUrl
+	}
+
+	return strings.TrimSuffix(base, "/") + r.URL.RequestURI(), nil
+}
+
+// authRequestFrom finds the auth request in either the Authorization header or
+// the POSTed form.
+func authRequestFrom(r *http.Request) string {
+
+	auth := r.Header.Get("Authorization")
+	if len(auth) > len(AUTH_SCHEME) && strings.EqualFold(auth[:len(AUTH_SCHEME)], AUTH_SCHEME) && auth[len(AUTH_SCHEME)] == ' ' {
+		return strings.TrimSpace(auth[len(AUTH_SCHEME)+1:])
+	}
+
+	if r.Method == "POST" {
+		return r.PostFormValue(AUTH_FORM_FIELD)
+	}
+
+	return ""
+}
@@ -0,0 +1,122 @@
+package octokey
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAuthHandler(t *testing.T) {
+
+	O := &Octokey{ChallengeSecret: []byte("hello world")}
+	signer := newTestSigner(t, "conrad")
+	keys := NewMemoryKeyStore()
+	keys.AddKey("conrad", signer.PublicKey())
+
+	h := O.NewAuthHandler(keys, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, _ := UsernameFromContext(r.Context())
+		w.Write([]byte("hello " + username))
+	}))
+	h.ChallengePath = "/challenge"
+	h.BaseUrl = "http://example.com"
+
+	do := func(r *http.Request) *httptest.ResponseRecorder {
+		r.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do(httptest.NewRequest("GET", "http://example.com/secret", nil))
+	if w.Code != 401 {
+		t.Fatal("expected 401, got", w.Code)
+	}
+
+	header := w.Header().Get("WWW-Authenticate")
+	if !strings.HasPrefix(header, `Octokey challenge="`) {
+		t.Fatal("missing challenge", header)
+	}
+	challenge := strings.TrimSuffix(strings.TrimPrefix(header, `Octokey challenge="`), `"`)
+
+	s, err := O.SignChallenge(challenge, "http://example.com/secret", signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", "http://example.com/secret", nil)
+	r.Header.Set("Authorization", "Octokey "+s)
+	w = do(r)
+	if w.Code != 200 || w.Body.String() != "hello conrad" {
+		t.Error("header login failed", w.Code, w.Body.String())
+	}
+
+	r = httptest.NewRequest("GET", "http://example.com/other", nil)
+	r.Header.Set("Authorization", "Octokey "+s)
+	if w = do(r); w.Code != 401 {
+		t.Error("expected 401 for wrong url, got", w.Code)
+	}
+
+	spoofed, err := O.SignChallenge(challenge, "http://evil.com/secret", signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r = httptest.NewRequest("GET", "http://evil.com/secret", nil)
+	r.Header.Set("Authorization", "Octokey "+spoofed)
+	if w = do(r); w.Code != 401 {
+		t.Error("expected 401 for spoofed host, got", w.Code)
+	}
+
+	w = do(httptest.NewRequest("GET", "http://example.com/challenge", nil))
+	if w.Code != 200 {
+		t.Fatal("expected 200, got", w.Code)
+	}
+
+	s, err = O.SignChallenge(w.Body.String(), "http://example.com/login", signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r = httptest.NewRequest("POST", "http://example.com/login", strings.NewReader(url.Values{"auth_request": {s}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = do(r)
+	if w.Code != 200 || w.Body.String() != "hello conrad" {
+		t.Error("form login failed", w.Code, w.Body.String())
+	}
+
+	keys.RemoveKey("conrad", signer.PublicKey())
+	r = httptest.NewRequest("GET", "http://example.com/login", nil)
+	r.Header.Set("Authorization", "Octokey "+s)
+	if w = do(r); w.Code != 401 {
+		t.Error("expected 401 for unauthorized key, got", w.Code)
+	}
+}
+
+func TestAuthHandlerRequestUrl(t *testing.T) {
+
+	O := &Octokey{ChallengeSecret: []byte("hello world")}
+	h := O.NewAuthHandler(NewMemoryKeyStore(), http.NotFoundHandler())
+	r := httptest.NewRequest("GET", "http://example.com/secret?a=b", nil)
+
+	if _, err := h.requestUrl(r); err != ErrAuthHandlerRequestUrl {
+		t.Error("expected ErrAuthHandlerRequestUrl, got", err)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != 500 {
+		t.Error("expected 500 without a base url, got", w.Code)
+	}
+
+	O.Audience = "https://Example.com:443"
+	if u, _ := h.requestUrl(r); u != "https://example.com/secret?a=b" {
+		t.Error("unexpected url from Audience", u)
+	}
+
+	h.BaseUrl = "https://example.com/"
+	if u, _ := h.requestUrl(r); u != "https://example.com/secret?a=b" {
+		t.Error("unexpected url from BaseUrl", u)
+	}
+}
@@ -1,15 +1,20 @@
 package octokey
 
 import (
-	"crypto"
-	"crypto/rsa"
-	"crypto/sha1"
+	"bytes"
+	"errors"
 	"github.com/octokey/octokey-go/buffer"
+	"net"
 )
 
+// A Signer signs auth requests for a user. Use NewSigner to create one from
+// a private key.
 type Signer interface {
-	SignPKCS1v15(hash crypto.Hash, hashed []byte) (s []byte, err error)
-	PublicKey() *rsa.PublicKey
+	// PublicKey returns the key that verifies the signatures.
+	PublicKey() Key
+	// Sign signs the data with the signing algorithm, and returns the
+	// signature in the format used by ssh, without the algorithm name.
+	Sign(algorithm string, data []byte) ([]byte, error)
 	Username() string
 }
 
@@ -20,45 +25,323 @@ type AuthRequest struct {
 	ServiceName      string
 	AuthMethod       string
 	SigningAlgorithm string
-	PublicKey        *rsa.PublicKey
+	PublicKey        Key
 	SignatureBuffer  *buffer.Buffer
+	O                *Octokey
+	Errors           []error
+	// ChannelBinding is covered by the signature but not included in the
+	// auth request, see SignBoundChallenge.
+	ChannelBinding []byte
+	// Challenge is set by ReadFrom
+	Challenge *Challenge
+	keyBuffer *buffer.Buffer
+	// stepUp is set by VerifyStepUp, only it accepts step-up challenges
+	stepUp bool
 }
 
 const SERVICE_NAME = "octokey-auth"
 
 const AUTH_METHOD = "publickey"
 
-const SIGNING_ALGORITHM = "ssh-rsa"
+const SIGNING_ALGORITHM = SIGNING_ALGORITHM_SSH_RSA
 
+var (
+	ErrAuthRequestInvalid                    = errors.New("octokey/auth_request: invalid auth request")
+	ErrAuthRequestMalformed                  = errors.New("octokey/auth_request: malformed auth request")
+	ErrAuthRequestUrlMismatch                = errors.New("octokey/auth_request: request url mismatch")
+	ErrAuthRequestOriginMismatch             = errors.New("octokey/auth_request: request origin mismatch")
+	ErrAuthRequestServiceNameMismatch        = errors.New("octokey/auth_request: service name mismatch")
+	ErrAuthRequestAuthMethodMismatch         = errors.New("octokey/auth_request: auth method mismatch")
+	ErrAuthRequestSigningAlgorithmMismatch   = errors.New("octokey/auth_request: signing algorithm mismatch")
+	ErrAuthRequestSignatureAlgorithmMismatch = errors.New("octokey/auth_request: signature algorithm mismatch")
+	ErrAuthRequestSignatureInvalid           = errors.New("octokey/auth_request: signature invalid")
+)
+
+// An AuthRequestError is returned by VerifyAuthRequest. It contains every
+// reason that the auth request was invalid, including problems with its
+// challenge, and can be inspected with errors.Is. It is also always
+// ErrAuthRequestInvalid.
+type AuthRequestError struct {
+	Errors []error
+}
+
+func (e *AuthRequestError) Error() string {
+	return joinErrors(ErrAuthRequestInvalid, e.Errors)
+}
+
+func (e *AuthRequestError) Is(target error) bool {
+	return target == ErrAuthRequestInvalid
+}
+
+func (e *AuthRequestError) Unwrap() []error {
+	return e.Errors
+}
+
+// SignChallenge creates an auth request for the given challenge and request url,
+// signed by the signer. The result is in Base64 format.
 func (O *Octokey) SignChallenge(challenge string, requestUrl string, signer Signer) (string, error) {
+	return O.SignBoundChallenge(challenge, requestUrl, nil, signer)
+}
+
+// SignBoundChallenge creates an auth request that can only be verified by a
+// server with the same channel binding, so that it can't be relayed to the
+// server by a man in the middle. Use ChannelBinding to get the channel
+// binding of the TLS connection that the auth request will be sent over.
+func (O *Octokey) SignBoundChallenge(challenge string, requestUrl string, channelBinding []byte, signer Signer) (string, error) {
+
+	challenge, err := unwrapChallenge(challenge, O.ServerKey)
+	if err != nil {
+		return "", err
+	}
+
 	a := AuthRequest{
 		ChallengeBuffer:  buffer.NewBuffer(challenge),
 		RequestUrl:       requestUrl,
 		Username:         signer.Username(),
 		ServiceName:      SERVICE_NAME,
 		AuthMethod:       AUTH_METHOD,
-		SigningAlgorithm: SIGNING_ALGORITHM,
+		SigningAlgorithm: signingAlgorithmFor(signer.PublicKey(), O.signingAlgorithm()),
+		O:                O,
+		ChannelBinding:   channelBinding,
 	}
 
 	return a.Sign(signer)
 }
 
+// VerifyAuthRequest checks that the auth request was signed in response to a
+// valid challenge for the given client ip and request url. It returns the
+// username and public key that the client authenticated with. It is still up
+// to you to check that the public key is allowed to log in as that user, for
+// example with Authorize.
+func (O *Octokey) VerifyAuthRequest(s string, requestUrl string, clientIp net.IP) (string, Key, error) {
+	return O.VerifyBoundAuthRequest(s, requestUrl, clientIp, nil)
+}
+
+// VerifyBoundAuthRequest verifies an auth request created by
+// SignBoundChallenge. The channel binding should be that of the TLS
+// connection that delivered the auth request. If the challenge was created by
+// NewBoundChallenge it must have the same channel binding too.
+func (O *Octokey) VerifyBoundAuthRequest(s string, requestUrl string, clientIp net.IP, channelBinding []byte) (string, Key, error) {
+
+	a, err := O.ReadAuthRequest(s, requestUrl, clientIp, channelBinding)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return a.Username, a.PublicKey, nil
+}
+
+// ReadAuthRequest verifies an auth request like VerifyBoundAuthRequest, and
+// returns it so that you can look at its Challenge, for example to read the
+// Payload. The channel binding should be nil unless the auth request was
+// created by SignBoundChallenge.
+func (O *Octokey) ReadAuthRequest(s string, requestUrl string, clientIp net.IP, channelBinding []byte) (*AuthRequest, error) {
+	return O.readAuthRequest(&AuthRequest{O: O, ChannelBinding: channelBinding}, s, requestUrl, clientIp)
+}
+
+// readAuthRequest reads and verifies the auth request into a.
+func (O *Octokey) readAuthRequest(a *AuthRequest, s string, requestUrl string, clientIp net.IP) (*AuthRequest, error) {
+
+	// Check the limit as soon as the username is known, so that a locked
+	// out client can't make us verify signatures or use up challenges.
+	if a.parse(s) && O.RateLimiter != nil && a.Username != "" {
+		err := O.RateLimiter.AllowAuthRequest(clientIp, a.Username)
+		if err != nil {
+			O.authRequestFailed(clientIp, a.Username, err)
+			return nil, err
+		}
+	}
+
+	if len(a.Errors) == 0 {
+		a.validate(requestUrl, clientIp)
+		a.use()
+	}
+
+	if O.Observer != nil && a.Challenge != nil && len(a.Challenge.Errors) > 0 {
+		O.Observer.ChallengeRejected(clientIp, &ChallengeError{Errors: a.Challenge.Errors})
+	}
+
+	// Failures are reset by Authorize, once the key is known to be allowed
+	// to log in as the user.
+	if O.RateLimiter != nil && a.Username != "" && len(a.Errors) > 0 {
+		O.RateLimiter.AuthRequestFailed(clientIp, a.Username)
+	}
+
+	if len(a.Errors) > 0 {
+		err := &AuthRequestError{Errors: a.Errors}
+		O.authRequestFailed(clientIp, a.Username, err)
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// authRequestFailed tells the Observer that an auth request failed.
+func (O *Octokey) authRequestFailed(clientIp net.IP, username string, err error) {
+	if O.Observer != nil {
+		O.Observer.AuthRequestFailed(clientIp, username, err)
+	}
+}
+
+// ReadFrom reads the auth request from its Base64 format, and validates it.
+// Any problems are added to a.Errors. If it is valid its challenge is used.
+func (a *AuthRequest) ReadFrom(s string, requestUrl string, clientIp net.IP) {
+	if a.parse(s) {
+		a.validate(requestUrl, clientIp)
+		a.use()
+	}
+}
+
+// parse reads the fields of the auth request from its Base64 format. It
+// returns false, and adds an error, if the auth request is malformed.
+func (a *AuthRequest) parse(s string) bool {
+	b := buffer.NewBuffer(s)
+
+	a.ChallengeBuffer = b.ScanBuffer()
+	a.RequestUrl = b.ScanString()
+	a.Username = b.ScanString()
+	a.ServiceName = b.ScanString()
+	a.AuthMethod = b.ScanString()
+	a.SigningAlgorithm = b.ScanString()
+	a.keyBuffer = b.ScanBuffer()
+	a.SignatureBuffer = b.ScanBuffer()
+	b.ScanEof()
+
+	if b.Error != nil {
+		a.Errors = append(a.Errors, malformed(ErrAuthRequestMalformed, b.Error))
+		return false
+	}
+
+	return true
+}
+
+// validate checks a parsed auth request, without using its challenge, and
+// adds any problems to a.Errors.
+func (a *AuthRequest) validate(requestUrl string, clientIp net.IP) {
+
+	keyBuffer := a.keyBuffer
+	key, err := ReadKey(keyBuffer)
+	keyBuffer.ScanEof()
+
+	if err == nil {
+		err = keyBuffer.Error
+	}
+
+	if err != nil {
+		a.Errors = append(a.Errors, malformed(ErrAuthRequestMalformed, err))
+		return
+	}
+
+	a.PublicKey = key
+
+	if err := a.O.checkKey(key); err != nil {
+		a.Errors = append(a.Errors, err)
+	}
+
+	challenge := &Challenge{O: a.O, ChannelBinding: a.ChannelBinding}
+	challenge.ReadFrom(a.ChallengeBuffer.String(), clientIp)
+	a.Challenge = challenge
+	a.Errors = append(a.Errors, challenge.Errors...)
+
+	if _, ok := stepUpOperation(challenge.Payload); ok && !a.stepUp {
+		a.Errors = append(a.Errors, ErrStepUpChallenge)
+	}
+
+	if a.RequestUrl != requestUrl {
+		a.Errors = append(a.Errors, ErrAuthRequestUrlMismatch)
+	}
+
+	if !a.O.originMatches(a.RequestUrl) {
+		a.Errors = append(a.Errors, ErrAuthRequestOriginMismatch)
+	}
+
+	if a.ServiceName != SERVICE_NAME {
+		a.Errors = append(a.Errors, ErrAuthRequestServiceNameMismatch)
+	}
+
+	if a.AuthMethod != AUTH_METHOD {
+		a.Errors = append(a.Errors, ErrAuthRequestAuthMethodMismatch)
+	}
+
+	if !a.O.acceptsSigningAlgorithm(a.SigningAlgorithm) {
+		a.Errors = append(a.Errors, ErrAuthRequestSigningAlgorithmMismatch)
+	}
+
+	err = a.verifySignature()
+	if err != nil {
+		a.Errors = append(a.Errors, err)
+	}
+}
+
+// use marks the challenge of a valid auth request as used, adding an error
+// if it had already been used.
+func (a *AuthRequest) use() {
+	if len(a.Errors) == 0 {
+		a.Challenge.use()
+		a.Errors = append(a.Errors, a.Challenge.Errors...)
+	}
+}
+
+// verifySignature checks that the signature buffer contains a valid signature
+// of the unsignedBuffer by the public key.
+func (a *AuthRequest) verifySignature() error {
+
+	s := &buffer.Buffer{Buffer: *bytes.NewBuffer(a.SignatureBuffer.Raw())}
+	algorithm := s.ScanString()
+	sig := s.ScanVarBytes()
+	s.ScanEof()
+
+	if s.Error != nil {
+		return malformed(ErrAuthRequestMalformed, s.Error)
+	}
+
+	if algorithm != a.SigningAlgorithm {
+		return ErrAuthRequestSignatureAlgorithmMismatch
+	}
+
+	b := a.unsignedBuffer()
+
+	if b.Error != nil {
+		return b.Error
+	}
+
+	err := a.PublicKey.Verify(algorithm, a.toSign(b), sig)
+	if err == ErrKeySignatureAlgorithm {
+		return ErrAuthRequestSignatureAlgorithmMismatch
+	}
+	if err != nil {
+		return ErrAuthRequestSignatureInvalid
+	}
+
+	return nil
+}
+
+// Sign fills in the public key and signature of the auth request and returns
+// it in Base64 format.
 func (a *AuthRequest) Sign(s Signer) (string, error) {
 	a.PublicKey = s.PublicKey()
 
+	if _, ok := signingAlgorithms[a.SigningAlgorithm]; !ok {
+		return "", ErrPolicySigningAlgorithm
+	}
+
 	b := a.unsignedBuffer()
 
-	h := sha1.New()
-	h.Write(b.Raw())
-	digest := h.Sum(nil)
+	if b.Error != nil {
+		return "", b.Error
+	}
 
-	sig, err := s.SignPKCS1v15(crypto.SHA1, digest)
+	sig, err := s.Sign(a.SigningAlgorithm, a.toSign(b))
 
 	if err != nil {
 		return "", err
 	}
 
-	b.AddVarBytes(sig)
+	a.SignatureBuffer = new(buffer.Buffer)
+	a.SignatureBuffer.AddString(a.SigningAlgorithm)
+	a.SignatureBuffer.AddVarBytes(sig)
+
+	b.AddBuffer(a.SignatureBuffer)
 
 	if b.Error != nil {
 		return "", b.Error
@@ -67,15 +350,53 @@ func (a *AuthRequest) Sign(s Signer) (string, error) {
 	return b.String(), nil
 }
 
+// unsignedBuffer is an octokey buffer containing everything except the
+// signature. The layout matches the ruby implementation: the challenge,
+// request url, username, service name, auth method, signing algorithm and
+// the public key in ssh wire format.
 func (a *AuthRequest) unsignedBuffer() *buffer.Buffer {
-	b := buffer.Buffer{}
+	b := new(buffer.Buffer)
+
+	if a.ChallengeBuffer.Error != nil {
+		b.Error = a.ChallengeBuffer.Error
+		return b
+	}
+
 	b.AddBuffer(a.ChallengeBuffer)
 	b.AddString(a.RequestUrl)
 	b.AddString(a.Username)
 	b.AddString(a.ServiceName)
 	b.AddString(a.AuthMethod)
 	b.AddString(a.SigningAlgorithm)
-	//	b.AddVarBytes(publicKeyBytes(a.PublicKey))
-	panic("todo")
-	return &b
+
+	k := a.publicKeyBuffer()
+	if k.Error != nil {
+		b.Error = k.Error
+		return b
+	}
+
+	b.AddBuffer(k)
+	return b
+}
+
+// toSign returns the bytes covered by the signature, which are the unsigned
+// buffer followed by the channel binding if there is one. The channel binding
+// is not sent, both sides get it from their own end of the TLS connection.
+func (a *AuthRequest) toSign(b *buffer.Buffer) []byte {
+
+	if len(a.ChannelBinding) == 0 {
+		return b.Raw()
+	}
+
+	t := new(buffer.Buffer)
+	t.Write(b.Raw())
+	t.AddVarBytes(a.ChannelBinding)
+	return t.Raw()
+}
+
+// publicKeyBuffer is an octokey buffer containing the public key
+func (a *AuthRequest) publicKeyBuffer() *buffer.Buffer {
+	b := new(buffer.Buffer)
+	a.PublicKey.WriteBuffer(b)
+	return b
 }
@@ -0,0 +1,254 @@
+package octokey
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"errors"
+	"github.com/octokey/octokey-go/buffer"
+	"math/big"
+	"net"
+	"testing"
+)
+
+type testSigner struct {
+	Signer
+	key *rsa.PrivateKey
+}
+
+func newTestSigner(t *testing.T, username string) *testSigner {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewSigner(username, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testSigner{signer, key}
+}
+
+func TestSignChallenge(t *testing.T) {
+
+	O := &Octokey{ChallengeSecret: []byte("hello world")}
+	signer := newTestSigner(t, "conrad")
+
+	c, err := O.NewChallenge(net.ParseIP("127.0.0.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := O.SignChallenge(c, "https://example.com/login", signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := buffer.NewBuffer(s)
+	challenge := b.ScanBuffer()
+	requestUrl := b.ScanString()
+	username := b.ScanString()
+	serviceName := b.ScanString()
+	authMethod := b.ScanString()
+	signingAlgorithm := b.ScanString()
+	keyBuffer := b.ScanBuffer()
+	signatureBuffer := b.ScanBuffer()
+	b.ScanEof()
+
+	if b.Error != nil {
+		t.Fatal(b.Error)
+	}
+
+	if challenge.String() != c || requestUrl != "https://example.com/login" || username != "conrad" ||
+		serviceName != SERVICE_NAME || authMethod != AUTH_METHOD || signingAlgorithm != SIGNING_ALGORITHM {
+		t.Fatal("auth request fields mismatch")
+	}
+
+	key := new(PublicKey)
+	err = key.ReadBuffer(keyBuffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.N.Cmp(signer.key.N) != 0 {
+		t.Fatal("public key mismatch")
+	}
+
+	if signatureBuffer.ScanString() != SIGNING_ALGORITHM {
+		t.Fatal("signature algorithm mismatch")
+	}
+	signature := signatureBuffer.ScanVarBytes()
+
+	// The signature covers everything before the signature buffer
+	unsigned := buffer.NewBuffer(s)
+	signatureLength := 4 + 4 + len(SIGNING_ALGORITHM) + 4 + len(signature)
+	unsigned.Truncate(unsigned.Len() - signatureLength)
+	digest := sha1.Sum(unsigned.Raw())
+
+	err = rsa.VerifyPKCS1v15(&signer.key.PublicKey, crypto.SHA1, digest[:], signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSignChallengeInvalidBase64(t *testing.T) {
+
+	O := &Octokey{ChallengeSecret: []byte("hello world")}
+
+	_, err := O.SignChallenge("not base64!", "https://example.com/login", newTestSigner(t, "conrad"))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestVerifyAuthRequest(t *testing.T) {
+
+	O := &Octokey{ChallengeSecret: []byte("hello world")}
+	signer := newTestSigner(t, "conrad")
+	clientIp := net.ParseIP("127.0.0.1")
+
+	c, err := O.NewChallenge(clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := O.SignChallenge(c, "https://example.com/login", signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	username, key, err := O.VerifyAuthRequest(s, "https://example.com/login", clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if username != "conrad" {
+		t.Error(username, "!=", "conrad")
+	}
+
+	if !key.Equal(signer.PublicKey()) {
+		t.Error("public key mismatch")
+	}
+}
+
+func TestVerifyAuthRequestErrors(t *testing.T) {
+
+	O := &Octokey{ChallengeSecret: []byte("hello world")}
+	signer := newTestSigner(t, "conrad")
+	clientIp := net.ParseIP("127.0.0.1")
+
+	c, err := O.NewChallenge(clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(f func(a *AuthRequest)) string {
+		a := AuthRequest{
+			ChallengeBuffer:  buffer.NewBuffer(c),
+			RequestUrl:       "https://example.com/login",
+			Username:         "conrad",
+			ServiceName:      SERVICE_NAME,
+			AuthMethod:       AUTH_METHOD,
+			SigningAlgorithm: SIGNING_ALGORITHM,
+		}
+		f(&a)
+		s, err := a.Sign(signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	tamper := func(s string) string {
+		b := buffer.NewBuffer(s)
+		raw := b.Raw()
+		raw[len(raw)-1] ^= 1
+		return b.String()
+	}
+
+	valid := sign(func(a *AuthRequest) {})
+	otherSecret := &Octokey{ChallengeSecret: []byte("goodbye world")}
+	noSha1 := &Octokey{ChallengeSecret: []byte("hello world"), AcceptedSigningAlgorithms: []string{SIGNING_ALGORITHM_RSA_SHA2_256}}
+
+	tests := []struct {
+		O          *Octokey
+		s          string
+		requestUrl string
+		clientIp   net.IP
+		errors     int
+		comment    string
+	}{
+		{O, valid, "https://example.com/login", clientIp, 0, "valid"},
+		{O, valid, "https://example.com/other", clientIp, 1, "wrong request url"},
+		{O, valid, "https://example.com/login", net.ParseIP("127.0.0.2"), 1, "wrong client ip"},
+		{otherSecret, valid, "https://example.com/login", clientIp, 1, "wrong challenge secret"},
+		{O, tamper(valid), "https://example.com/login", clientIp, 1, "bad signature"},
+		{O, sign(func(a *AuthRequest) { a.ServiceName = "ssh-connection" }), "https://example.com/login", clientIp, 1, "wrong service name"},
+		{O, sign(func(a *AuthRequest) { a.AuthMethod = "password" }), "https://example.com/login", clientIp, 1, "wrong auth method"},
+		{noSha1, valid, "https://example.com/login", clientIp, 1, "signing algorithm not accepted"},
+		{noSha1, sign(func(a *AuthRequest) { a.SigningAlgorithm = SIGNING_ALGORITHM_RSA_SHA2_256 }), "https://example.com/login", clientIp, 0, "rsa-sha2-256"},
+		{O, valid[:len(valid)-8], "https://example.com/login", clientIp, 1, "truncated"},
+	}
+
+	for _, test := range tests {
+		a := AuthRequest{O: test.O}
+		a.ReadFrom(test.s, test.requestUrl, test.clientIp)
+
+		if len(a.Errors) != test.errors {
+			t.Error(test.comment, a.Errors)
+		}
+
+		_, _, err := test.O.VerifyAuthRequest(test.s, test.requestUrl, test.clientIp)
+		if (err == nil) != (test.errors == 0) {
+			t.Error(test.comment, err)
+		}
+	}
+}
+
+func TestVerifyAuthRequestMalformedKey(t *testing.T) {
+
+	O := &Octokey{ChallengeSecret: []byte("hello world")}
+	clientIp := net.ParseIP("127.0.0.1")
+
+	c, err := O.NewChallenge(clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsaKey := func(e []byte, n []byte) *buffer.Buffer {
+		key := new(buffer.Buffer)
+		key.AddString(PUBLIC_KEY_TYPE)
+		key.AddVarBytes(e)
+		key.AddVarBytes(n)
+		return key
+	}
+
+	tests := []struct {
+		key     *buffer.Buffer
+		err     error
+		comment string
+	}{
+		{rsaKey([]byte{0x00}, []byte{0x01}), ErrAuthRequestMalformed, "exponent is a lone 0x00"},
+		{rsaKey(big.NewInt(EXPONENT).Bytes(), append([]byte{0x40}, make([]byte, 127)...)), ErrPublicKeyModulusEven, "even modulus"},
+	}
+
+	for _, test := range tests {
+		signature := new(buffer.Buffer)
+		signature.AddString(SIGNING_ALGORITHM)
+		signature.AddVarBytes([]byte{0x00})
+
+		b := new(buffer.Buffer)
+		b.AddBuffer(buffer.NewBuffer(c))
+		b.AddString("https://example.com/login")
+		b.AddString("conrad")
+		b.AddString(SERVICE_NAME)
+		b.AddString(AUTH_METHOD)
+		b.AddString(SIGNING_ALGORITHM)
+		b.AddBuffer(test.key)
+		b.AddBuffer(signature)
+
+		_, _, err = O.VerifyAuthRequest(b.String(), "https://example.com/login", clientIp)
+		if !errors.Is(err, ErrAuthRequestMalformed) || !errors.Is(err, test.err) {
+			t.Error(test.comment, "expected", test.err, "got", err)
+		}
+	}
+}
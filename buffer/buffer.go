@@ -34,8 +34,17 @@ func (b *Buffer) ScanUint8() (x uint8) {
 	return x
 }
 
+func (b *Buffer) AddUint32(x uint32) {
+	b.binaryWrite(x)
+}
+
+func (b *Buffer) ScanUint32() (x uint32) {
+	b.binaryRead(&x)
+	return x
+}
+
 func (b *Buffer) AddTimestamp(t time.Time) {
-	x := uint64(t.Unix()*1000) + uint64(t.Nanosecond()/1000)
+	x := uint64(t.Unix()*1000) + uint64(t.Nanosecond()/1000000)
 	b.binaryWrite(x)
 }
 
@@ -44,7 +53,7 @@ func (b *Buffer) ScanTimestamp() (t time.Time) {
 	var tmp uint64
 	b.binaryRead(&tmp)
 
-	return time.Unix(int64(tmp/1000), int64(tmp%1000)*1000)
+	return time.Unix(int64(tmp/1000), int64(tmp%1000)*1000000)
 }
 
 func (b *Buffer) AddIP(ip net.IP) {
@@ -148,7 +157,7 @@ func (b *Buffer) ScanMPInt() (x *big.Int) {
 		return
 	}
 
-	if len(tmp) > 0 && tmp[0] == 0x00 && tmp[1] < 0x80 {
+	if len(tmp) > 0 && tmp[0] == 0x00 && (len(tmp) == 1 || tmp[1] < 0x80) {
 		b.Error = errors.New("octokey/buffer: not reading suspicious mpint")
 		return
 	}
@@ -39,12 +39,20 @@ func TestBuffer(t *testing.T) {
 			expected = uint8(ex)
 			write.AddUint8(expected.(uint8))
 
+		case "uint32":
+
+			actual = read.ScanUint32()
+			var ex uint64
+			ex, err = strconv.ParseUint(value, 10, 32)
+			expected = uint32(ex)
+			write.AddUint32(expected.(uint32))
+
 		case "timestamp":
 
 			actual = read.ScanTimestamp()
 			var ex uint64
 			ex, err = strconv.ParseUint(value, 10, 64)
-			expected = time.Unix(int64(ex/1000), int64(1000*(ex%1000)))
+			expected = time.Unix(int64(ex/1000), int64(1000000*(ex%1000)))
 			write.AddTimestamp(expected.(time.Time))
 
 		case "ip":
@@ -76,8 +84,8 @@ func TestBuffer(t *testing.T) {
 			actual = read.ScanMPInt()
 			var ex big.Int
 			_, err = fmt.Sscan(value, &ex)
-			expected = ex
-			write.AddMPInt(expected.(big.Int))
+			expected = &ex
+			write.AddMPInt(&ex)
 
 		default:
 
@@ -135,6 +143,14 @@ AQ==	uint8	1	ok	The next-lowest possible uint8
 TTg=	uint8	Buffer too long	error	Trailing bytes
 	uint8	Buffer too short	error	Empty buffer
 
+EjRWeA==	uint32	305419896	ok	A uint32 example
+AAAAAA==	uint32	0	ok	The lowest possible uint32
+AAAAAQ==	uint32	1	ok	The next-lowest possible uint32
+/////w==	uint32	4294967295	ok	The highest possible uint32
+EjRWeAA=	uint32	Buffer too long	error	Trailing bytes
+EjRW	uint32	Buffer too short	error	3/4 bytes
+	uint32	Buffer too short	error	Empty buffer
+
 AAABOHbFfDo=	timestamp	1342022450234	ok	A time example
 AAAAAAAAAAA=	timestamp	0	ok	The earliest possible time
 AAACAlfNCMQ=	timestamp	2209086245060	ok	A time after 2038 (http://en.wikipedia.org/wiki/Year_2038_problem)
@@ -189,6 +205,7 @@ AAAAAgD/	mpint	255	ok	Example with 0-prefix to avoid negativity
 AAABAQEAAAAAAAAAaOb1ifPLFFiItLecNyZdG0jt0pIjv4cjEyTolqjP4JjrWqht5/NmfwkZkl0GG/eU+eslseEFVeLbF5P7LdYTOWQS3VVGWcPtGw6oeTLfhp1RZJDmkkSJuvPLWOD/2wY2n4Uxw2aUc1o43lP9IwKffZ4x7m5Z8PVSEiIKLsKqOIRqpsPXF0c9Dd+M/FPWcpH2tbg+ACqWkbggIfa+vTqi29vR1SX+tckItHoYgHIkDaJnH2QUA8zbQH3rc78ZNxmwQ2PB4JIntwjJPHHpr3mS5hFBk1XTImcj1pJ0FWOuYqufciWtMoKyC8nJ0Bb2gQIaFPFkUGWHw88h	mpint	32317006071311007484493895237680372175326150891472064373579534958365689608253016077025862134931110863117450013928990979398198520686349575073072519919835263053614712869890235173758289561539115831709685663873976319771581240662146367918170630665919724687618629758709856186109709113973389999518112303208992426608469015986486191774207255809645707847257247251583686554637456060748507916854887287304525742769468196920822463784249313690835118005370848347364224817465644803864719170741042337319019550074901735539037513076142934879176870801869287771721729543685611017142102541535891363725950666715678754308385661894796765286177	ok	Example > 2 ** 2048
 AAAAAv//	mpint	Badly formatted mpint	error	Negative number
 AAAAAwAB/w==	mpint	Badly formatted mpint	error	Extra leading 0s
+AAAAAQA=	mpint	Badly formatted mpint	error	Zero encoded as a single 0
 	mpint	Buffer too short	error	Empty buffer
 AAI=	mpint	Buffer too short	error	Entire length is not present
 AAAAEA==	mpint	Buffer too short	error	Only length present
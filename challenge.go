@@ -3,64 +3,213 @@ package octokey
 import (
 	"crypto/hmac"
 	"crypto/rand"
-	"crypto/sha1"
+	"crypto/sha256"
 	"errors"
 	"github.com/octokey/octokey-go/buffer"
+	"hash"
+	"math"
 	"net"
 	"time"
 )
 
 const (
-	// Which version of challenges is supported
-	CHALLENGE_VERSION = 3
-	// How many bytes of random data should be included
+	// Version 3 challenges are signed with HMAC_ALGORITHM
+	CHALLENGE_VERSION_3 = 3
+	// Version 4 challenges are signed with HMAC-SHA256
+	CHALLENGE_VERSION_4 = 4
+	// Version 5 challenges are signed with HMAC-SHA256, and have a payload
+	CHALLENGE_VERSION_5 = 5
+	// Which version of challenges is issued by default
+	CHALLENGE_VERSION = CHALLENGE_VERSION_3
+	// The maximum size of a challenge payload (bytes)
+	MAX_PAYLOAD_SIZE = 1024
+	// How many bytes of random data should be included by default
 	RANDOM_SIZE = 32
-	// Hash algorithm to use in the HMAC
+	// Default hash algorithm to use in the HMAC
 	HMAC_ALGORITHM = "sha1"
-	// The maximum age of a valid challenge (seconds)
+	// The default maximum age of a valid challenge (seconds)
 	MAX_AGE = 5 * 60
-	// The minimum age of a valid challenge (seconds)
+	// The default minimum age of a valid challenge (seconds)
 	MIN_AGE = -30
 )
 
+// NO_MIN_AGE can be used as the MinAge of an Octokey to accept challenges
+// however far in the future their timestamp is. A MinAge of zero means
+// MIN_AGE seconds.
+const NO_MIN_AGE = time.Duration(math.MinInt64)
+
+var (
+	ErrChallengeInvalid         = errors.New("octokey/challenge: invalid challenge")
+	ErrChallengeMalformed       = errors.New("octokey/challenge: malformed challenge")
+	ErrChallengeVersionMismatch = errors.New("octokey/challenge: version mismatch")
+	ErrChallengeNotYetValid     = errors.New("octokey/challenge: challenge too new")
+	ErrChallengeExpired         = errors.New("octokey/challenge: challenge too old")
+	ErrChallengeIpMismatch      = errors.New("octokey/challenge: challenge IP mismatch")
+	ErrChallengeRandomMismatch  = errors.New("octokey/challenge: challenge random mismatch")
+	ErrChallengeHmacMismatch    = errors.New("octokey/challenge: challenge HMAC mismatch")
+	ErrChallengeUsed            = errors.New("octokey/challenge: challenge already used")
+
+	ErrChallengePayloadUnsupported = errors.New("octokey/challenge: payloads need version 5 challenges")
+	ErrChallengePayloadTooLarge    = errors.New("octokey/challenge: payload too large")
+)
+
+// A ChallengeError is returned by ValidateChallenge. It contains every reason
+// that the challenge was invalid, and can be inspected with errors.Is, for
+// example errors.Is(err, ErrChallengeExpired). It is also always
+// ErrChallengeInvalid.
+type ChallengeError struct {
+	Errors []error
+}
+
+func (e *ChallengeError) Error() string {
+	return joinErrors(ErrChallengeInvalid, e.Errors)
+}
+
+func (e *ChallengeError) Is(target error) bool {
+	return target == ErrChallengeInvalid
+}
+
+func (e *ChallengeError) Unwrap() []error {
+	return e.Errors
+}
+
 type Challenge struct {
 	O         *Octokey
 	Version   uint8
 	Timestamp time.Time
 	ClientIp  net.IP
 	Random    []byte
-	Digest    []byte
-	Errors    []error
+	// Payload is only included in version 5 challenges
+	Payload []byte
+	Digest  []byte
+	Errors  []error
+	// SecretId is the id of the secret that signed the challenge
+	SecretId string
+	// ChannelBinding is covered by the HMAC but not included in the
+	// challenge, see NewBoundChallenge.
+	ChannelBinding []byte
+	secret         []byte
 }
 
 func (O *Octokey) NewChallenge(clientIp net.IP) (string, error) {
+	return O.NewBoundChallenge(clientIp, nil)
+}
+
+// NewBoundChallenge creates a challenge that can only be validated over a TLS
+// connection with the same channel binding, see ChannelBinding.
+func (O *Octokey) NewBoundChallenge(clientIp net.IP, channelBinding []byte) (string, error) {
+	return O.IssueChallenge(&Challenge{ClientIp: clientIp, ChannelBinding: channelBinding})
+}
+
+// NewChallengeWithPayload creates a challenge that carries a small payload,
+// for example where to redirect the user after logging in. The payload is
+// covered by the HMAC, but it is not encrypted so the client can read it.
+// Payloads need version 5 challenges.
+func (O *Octokey) NewChallengeWithPayload(clientIp net.IP, payload []byte) (string, error) {
+	return O.IssueChallenge(&Challenge{ClientIp: clientIp, Payload: payload})
+}
+
+// IssueChallenge fills in and signs a challenge with the given ClientIp,
+// ChannelBinding and Payload, and returns it in Base64 format.
+func (O *Octokey) IssueChallenge(c *Challenge) (string, error) {
+
+	err := O.CheckPolicy()
+	if err != nil {
+		return "", err
+	}
 
-	random := make([]byte, RANDOM_SIZE)
-	_, err := rand.Read(random)
+	secret, err := O.currentSecret()
 	if err != nil {
 		return "", err
 	}
 
-	challenge := Challenge{O: O}
-	challenge.Version = CHALLENGE_VERSION
-	challenge.Timestamp = now()
-	challenge.ClientIp = clientIp
-	challenge.Random = random
-	challenge.Digest = challenge.expectedDigest()
+	if c.ClientIp == nil && O.IpBinding == IP_BINDING_NONE {
+		c.ClientIp = net.IPv6unspecified
+	}
+
+	if O.RateLimiter != nil {
+		err = O.RateLimiter.AllowChallenge(c.ClientIp)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	c.O = O
+	c.Version = O.challengeVersion()
+
+	if len(c.Payload) > 0 && c.Version < CHALLENGE_VERSION_5 {
+		return "", ErrChallengePayloadUnsupported
+	}
+
+	if len(c.Payload) > MAX_PAYLOAD_SIZE {
+		return "", ErrChallengePayloadTooLarge
+	}
+
+	c.Random = make([]byte, O.randomSize())
+	_, err = rand.Read(c.Random)
+	if err != nil {
+		return "", err
+	}
+
+	c.Timestamp = O.now()
+	c.SecretId = secret.Id
+	c.secret = secret.Value
+	c.Digest = c.expectedDigest()
+
+	s := c.String()
+	if O.ServerSigner != nil {
+		s, err = O.signAsServer(c.signedBuffer())
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if O.Observer != nil {
+		O.Observer.ChallengeIssued(c.ClientIp, c)
+	}
 
-	return challenge.String(), nil
+	return s, nil
 }
 
 func (O *Octokey) ValidateChallenge(s string, clientIp net.IP) error {
+	return O.ValidateBoundChallenge(s, clientIp, nil)
+}
+
+// ValidateBoundChallenge validates a challenge created by NewBoundChallenge.
+// If the channel binding is different the challenge has an HMAC mismatch.
+func (O *Octokey) ValidateBoundChallenge(s string, clientIp net.IP, channelBinding []byte) error {
+	_, err := O.ReadChallenge(s, clientIp, channelBinding)
+	return err
+}
+
+// ReadChallenge validates a challenge like ValidateBoundChallenge, and
+// returns it so that you can look at its Payload. The channel binding
+// should be nil unless the challenge was created by NewBoundChallenge.
+func (O *Octokey) ReadChallenge(s string, clientIp net.IP, channelBinding []byte) (*Challenge, error) {
+
+	challenge := &Challenge{O: O, ChannelBinding: channelBinding}
 
-	challenge := Challenge{O: O}
-	challenge.ReadFrom(s, clientIp)
+	// The server doesn't need to check its own signature, as the challenge
+	// has an HMAC.
+	if unwrapped, err := unwrapChallenge(s, nil); err != nil {
+		challenge.Errors = append(challenge.Errors, err)
+	} else {
+		challenge.ReadFrom(unwrapped, clientIp)
+	}
+
+	if len(challenge.Errors) == 0 {
+		challenge.use()
+	}
 
 	if len(challenge.Errors) > 0 {
-		return errors.New("octokey/challenge: invalid challenge")
+		err := &ChallengeError{Errors: challenge.Errors}
+		if O.Observer != nil {
+			O.Observer.ChallengeRejected(clientIp, err)
+		}
+		return nil, err
 	}
 
-	return nil
+	return challenge, nil
 }
 
 // String returns the challenge in Base64 format
@@ -70,54 +219,129 @@ func (c *Challenge) String() string {
 
 func (c *Challenge) ReadFrom(s string, clientIp net.IP) {
 	b := buffer.NewBuffer(s)
-	currentTime := now()
+	currentTime := c.O.now()
 
-	c.Version = b.ScanUint8()
-	c.Timestamp = b.ScanTimestamp()
-	c.ClientIp = b.ScanIP()
-	c.Random = b.ScanVarBytes()
-	c.Digest = b.ScanVarBytes()
-	b.ScanEof()
+	err := c.O.CheckPolicy()
+	if err != nil {
+		c.Errors = append(c.Errors, err)
+		return
+	}
+
+	c.scanBuffer(b)
 
 	if b.Error != nil {
-		c.Errors = append(c.Errors, b.Error)
+		c.Errors = append(c.Errors, malformed(ErrChallengeMalformed, b.Error))
 		return
 	}
 
-	if c.Version != CHALLENGE_VERSION {
-		c.Errors = append(c.Errors, errors.New("octokey/challenge: version mismatch"))
+	if !c.O.acceptsVersion(c.Version) {
+		c.Errors = append(c.Errors, ErrChallengeVersionMismatch)
 		return
 	}
 
-	if currentTime.Unix()+MAX_AGE < c.Timestamp.Unix() {
-		c.Errors = append(c.Errors, errors.New("octokey/challenge: challenge too new"))
+	age := currentTime.Sub(c.Timestamp)
+
+	if age < c.O.minAge() {
+		c.Errors = append(c.Errors, ErrChallengeNotYetValid)
+	}
+
+	if age > c.O.maxAge() {
+		c.Errors = append(c.Errors, ErrChallengeExpired)
+	}
+
+	if !c.O.ipMatches(c.ClientIp, clientIp) {
+		c.Errors = append(c.Errors, ErrChallengeIpMismatch)
+	}
+
+	if len(c.Random) != c.O.randomSize() {
+		c.Errors = append(c.Errors, ErrChallengeRandomMismatch)
+	}
+
+	if !c.verifyDigest() {
+		c.Errors = append(c.Errors, ErrChallengeHmacMismatch)
 	}
+}
 
-	if currentTime.Unix()+MIN_AGE > c.Timestamp.Unix() {
-		c.Errors = append(c.Errors, errors.New("octokey/challenge: challenge too old"))
+// scanBuffer reads the challenge from a buffer without validating it.
+func (c *Challenge) scanBuffer(b *buffer.Buffer) {
+	c.Version = b.ScanUint8()
+	c.Timestamp = b.ScanTimestamp()
+	c.ClientIp = b.ScanIP()
+	c.Random = b.ScanVarBytes()
+	if c.Version >= CHALLENGE_VERSION_5 {
+		c.Payload = b.ScanVarBytes()
 	}
+	c.Digest = b.ScanVarBytes()
+	b.ScanEof()
+}
+
+// use marks a valid challenge as used in the ChallengeStore, adding an error
+// if it had already been used.
+func (c *Challenge) use() {
 
-	if !c.ClientIp.Equal(clientIp) {
-		c.Errors = append(c.Errors, errors.New("octokey/challenge: challenge IP mismatch"))
+	if c.O.ChallengeStore == nil {
+		return
 	}
 
-	if len(c.Random) != RANDOM_SIZE {
-		c.Errors = append(c.Errors, errors.New("octokey/challenge: challenge random mismatch"))
+	expires := c.Timestamp.Add(c.O.maxAge())
+	ok, err := c.O.ChallengeStore.Use(c.Random, expires)
+
+	if err != nil {
+		c.Errors = append(c.Errors, err)
+	} else if !ok {
+		c.Errors = append(c.Errors, ErrChallengeUsed)
 	}
+}
 
-	if !hmac.Equal(c.Digest, c.expectedDigest()) {
-		c.Errors = append(c.Errors, errors.New("octokey/challenge: challenge HMAC mismatch"))
+// verifyDigest checks whether the challenge was signed by any of the active
+// secrets, and if so remembers which one.
+func (c *Challenge) verifyDigest() bool {
+	for _, s := range c.O.activeSecrets() {
+		if hmac.Equal(c.Digest, c.digest(s.Value)) {
+			c.SecretId = s.Id
+			c.secret = s.Value
+			return true
+		}
 	}
+	return false
 }
 
-// expectedDigest calculates the HMAC of the unsignedBuffer
+// expectedDigest calculates the HMAC of the unsignedBuffer with the secret
+// that signed the challenge, or the current secret if that is not known.
 func (c *Challenge) expectedDigest() []byte {
-	toSign := c.unsignedBuffer().Raw()
-	h := hmac.New(sha1.New, c.O.ChallengeSecret)
-	h.Write(toSign)
+	secret := c.secret
+	if secret == nil {
+		s, _ := c.O.currentSecret()
+		secret = s.Value
+	}
+	return c.digest(secret)
+}
+
+// digest calculates the HMAC of the unsignedBuffer with the given secret. The
+// audience and channel binding are covered by the HMAC too, though they are
+// not part of the challenge.
+func (c *Challenge) digest(secret []byte) []byte {
+	toSign := c.unsignedBuffer()
+	if audience := c.O.audience(); audience != "" {
+		toSign.AddString(audience)
+	}
+	if len(c.ChannelBinding) > 0 {
+		toSign.AddVarBytes(c.ChannelBinding)
+	}
+	h := hmac.New(c.hmacHash(), secret)
+	h.Write(toSign.Raw())
 	return h.Sum(nil)
 }
 
+// hmacHash returns the hash used to sign the challenge, which depends on its
+// version.
+func (c *Challenge) hmacHash() func() hash.Hash {
+	if c.Version >= CHALLENGE_VERSION_4 {
+		return sha256.New
+	}
+	return hmacAlgorithms[c.O.hmacAlgorithm()]
+}
+
 // unsignedBuffer is an octokey buffer containing everything except the signature
 func (c *Challenge) unsignedBuffer() *buffer.Buffer {
 	b := &buffer.Buffer{}
@@ -125,6 +349,9 @@ func (c *Challenge) unsignedBuffer() *buffer.Buffer {
 	b.AddTimestamp(c.Timestamp)
 	b.AddIP(c.ClientIp)
 	b.AddVarBytes(c.Random)
+	if c.Version >= CHALLENGE_VERSION_5 {
+		b.AddVarBytes(c.Payload)
+	}
 	return b
 }
 
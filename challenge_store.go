@@ -0,0 +1,63 @@
+package octokey
+
+import (
+	"sync"
+	"time"
+)
+
+// A ChallengeStore remembers which challenges have been used so that each one
+// can only be used once. Challenges are identified by their random bytes.
+type ChallengeStore interface {
+	// Use marks the challenge as used until it expires. It returns false if
+	// the challenge had already been used.
+	Use(random []byte, expires time.Time) (bool, error)
+}
+
+// A MemoryChallengeStore is a ChallengeStore that keeps everything in memory.
+// If you run more than one server you will need to share a ChallengeStore
+// between them instead. The zero value is ready to use.
+type MemoryChallengeStore struct {
+	// Clock is used to forget about expired challenges. Defaults to the
+	// real time.
+	Clock Clock
+
+	used      map[string]time.Time
+	nextSweep time.Time
+	mutex     sync.Mutex
+}
+
+// How often a MemoryChallengeStore forgets about expired challenges (seconds)
+const CHALLENGE_STORE_SWEEP_INTERVAL = 60
+
+// NewMemoryChallengeStore creates an empty MemoryChallengeStore.
+func NewMemoryChallengeStore() *MemoryChallengeStore {
+	return &MemoryChallengeStore{}
+}
+
+func (s *MemoryChallengeStore) Use(random []byte, expires time.Time) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	currentTime := clockOrDefault(s.Clock).Now()
+
+	if s.used == nil {
+		s.used = make(map[string]time.Time)
+	}
+
+	if currentTime.After(s.nextSweep) {
+		for k, e := range s.used {
+			if currentTime.After(e) {
+				delete(s.used, k)
+			}
+		}
+		s.nextSweep = currentTime.Add(CHALLENGE_STORE_SWEEP_INTERVAL * time.Second)
+	}
+
+	if e, ok := s.used[string(random)]; ok && !currentTime.After(e) {
+		return false, nil
+	}
+
+	s.used[string(random)] = expires
+
+	return true, nil
+}
@@ -0,0 +1,71 @@
+package octokey
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestChallengeStore(t *testing.T) {
+
+	O := &Octokey{ChallengeSecret: []byte("hello world"), ChallengeStore: NewMemoryChallengeStore()}
+	clientIp := net.ParseIP("127.0.0.1")
+
+	c, err := O.NewChallenge(clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := O.ValidateChallenge(c, clientIp); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := O.ValidateChallenge(c, clientIp); err == nil {
+		t.Error("challenge could be used twice")
+	}
+
+	signer := newTestSigner(t, "conrad")
+	c, err = O.NewChallenge(clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := O.SignChallenge(c, "https://example.com/login", signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := O.VerifyAuthRequest(s, "https://example.com/other", clientIp); err == nil {
+		t.Fatal("auth request for wrong url was valid")
+	}
+
+	if _, _, err := O.VerifyAuthRequest(s, "https://example.com/login", clientIp); err != nil {
+		t.Fatal("invalid auth request used up the challenge", err)
+	}
+
+	if _, _, err := O.VerifyAuthRequest(s, "https://example.com/login", clientIp); err == nil {
+		t.Error("auth request could be used twice")
+	}
+}
+
+func TestMemoryChallengeStoreExpiry(t *testing.T) {
+
+	start := time.Date(2012, 7, 12, 22, 12, 58, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := &MemoryChallengeStore{Clock: clock}
+	random := []byte("0123456789abcdef0123456789abcdef")
+
+	if ok, _ := s.Use(random, start.Add(MAX_AGE*time.Second)); !ok {
+		t.Error("new challenge was already used")
+	}
+	if ok, _ := s.Use(random, start.Add(MAX_AGE*time.Second)); ok {
+		t.Error("challenge could be used twice")
+	}
+
+	clock.Advance((MAX_AGE + CHALLENGE_STORE_SWEEP_INTERVAL) * time.Second)
+	s.Use([]byte("another challenge"), clock.Now())
+
+	if len(s.used) != 1 {
+		t.Error("expired challenge was not forgotten")
+	}
+}
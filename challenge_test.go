@@ -45,29 +45,27 @@ func TestValidation(t *testing.T) {
 		}
 		clientIp := net.ParseIP(fields[2])
 
-		O := &Octokey{ChallengeSecret: []byte("12345")}
-
-		at(date, func() {
-			challenge := Challenge{O: O}
-			challenge.ReadFrom(buffer, clientIp)
-
-			if ok == "ok" {
-				if len(challenge.Errors) > 0 {
-					t.Error(comment, challenge.Errors)
-				}
-
-				if challenge.String() != buffer {
-					t.Error(comment, challenge.String(), "!=", buffer)
-				}
-			} else {
-				if len(challenge.Errors) == 0 {
-					t.Error(comment, "did not fail")
-				} else if len(challenge.Errors) != len(strings.Split(errors, ",")) {
-					t.Error(comment, challenge.Errors, "!=", strings.Split(errors, ","))
-				}
+		O := &Octokey{ChallengeSecret: []byte("12345"), Clock: NewFakeClock(date)}
 
+		challenge := Challenge{O: O}
+		challenge.ReadFrom(buffer, clientIp)
+
+		if ok == "ok" {
+			if len(challenge.Errors) > 0 {
+				t.Error(comment, challenge.Errors)
+			}
+
+			if challenge.String() != buffer {
+				t.Error(comment, challenge.String(), "!=", buffer)
 			}
-		})
+		} else {
+			if len(challenge.Errors) == 0 {
+				t.Error(comment, "did not fail")
+			} else if len(challenge.Errors) != len(strings.Split(errors, ",")) {
+				t.Error(comment, challenge.Errors, "!=", strings.Split(errors, ","))
+			}
+
+		}
 
 	}
 
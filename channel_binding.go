@@ -0,0 +1,36 @@
+package octokey
+
+import (
+	"crypto/tls"
+	"errors"
+)
+
+const (
+	// The RFC 5705 label used to export keying material for channel binding
+	CHANNEL_BINDING_LABEL = "EXPORTER-octokey-channel-binding"
+	// How many bytes of keying material to export
+	CHANNEL_BINDING_SIZE = 32
+)
+
+var (
+	ErrChannelBindingUnavailable = errors.New("octokey/channel_binding: no TLS connection")
+)
+
+// ChannelBinding returns a value that is the same at both ends of a TLS
+// connection, but different for every connection, using RFC 5705 keying
+// material exporters. Pass it to NewBoundChallenge, ValidateBoundChallenge,
+// SignBoundChallenge and VerifyBoundAuthRequest so that challenges and auth
+// requests can't be relayed to the server over a different connection.
+//
+// On the server use r.TLS from the http.Request, and on the client use
+// resp.TLS from the response that contained the challenge. TLS 1.2
+// connections without the extended master secret are rejected, as their
+// keying material is not unique to the connection.
+func ChannelBinding(cs *tls.ConnectionState) ([]byte, error) {
+
+	if cs == nil || !cs.HandshakeComplete {
+		return nil, ErrChannelBindingUnavailable
+	}
+
+	return cs.ExportKeyingMaterial(CHANNEL_BINDING_LABEL, nil, CHANNEL_BINDING_SIZE)
+}
@@ -0,0 +1,92 @@
+package octokey
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChannelBinding(t *testing.T) {
+
+	var serverBinding []byte
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		serverBinding, err = ChannelBinding(r.TLS)
+		if err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	res, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	clientBinding, err := ChannelBinding(res.TLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(clientBinding) != CHANNEL_BINDING_SIZE || !bytes.Equal(clientBinding, serverBinding) {
+		t.Error("channel bindings differ", clientBinding, serverBinding)
+	}
+
+	if _, err := ChannelBinding(nil); err != ErrChannelBindingUnavailable {
+		t.Error("expected ErrChannelBindingUnavailable, got", err)
+	}
+}
+
+func TestBoundAuthRequest(t *testing.T) {
+
+	O := &Octokey{ChallengeSecret: []byte("hello world")}
+	signer := newTestSigner(t, "conrad")
+	clientIp := net.ParseIP("127.0.0.1")
+	binding := []byte("the real connection")
+	relayed := []byte("the attacker's connection")
+
+	c, err := O.NewBoundChallenge(clientIp, binding)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := O.ValidateBoundChallenge(c, clientIp, relayed); err == nil {
+		t.Error("challenge valid with the wrong channel binding")
+	}
+	if err := O.ValidateChallenge(c, clientIp); err == nil {
+		t.Error("bound challenge valid without channel binding")
+	}
+	if err := O.ValidateBoundChallenge(c, clientIp, binding); err != nil {
+		t.Error(err)
+	}
+
+	tests := []struct {
+		signed   []byte
+		verified []byte
+		valid    bool
+		comment  string
+	}{
+		{binding, binding, true, "same connection"},
+		{relayed, binding, false, "relayed by a man in the middle"},
+		{nil, binding, false, "client did not bind"},
+		{binding, nil, false, "server did not bind"},
+	}
+
+	for _, test := range tests {
+		s, err := O.SignBoundChallenge(c, "https://example.com/login", test.signed, signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, _, err = O.VerifyBoundAuthRequest(s, "https://example.com/login", clientIp, test.verified)
+		if (err == nil) != test.valid {
+			t.Error(test.comment, err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package octokey
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// RemoteIP returns the IP address of the client that connected to the server.
+// It does not look at any headers set by proxies.
+func RemoteIP(r *http.Request) net.IP {
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return parseIP(host)
+}
+
+// ClientIP returns the IP address of the client that made the request, taking
+// into account the Forwarded (RFC 7239) or X-Forwarded-For headers added by
+// trusted proxies.
+//
+// The chain of addresses is followed backwards from the address that connected
+// to the server, and the first address that is not a trusted proxy is returned.
+// Addresses added by untrusted clients are never looked at. If there is no
+// usable address in the headers, the last trusted proxy is returned.
+func ClientIP(r *http.Request, trustedProxies []*net.IPNet) net.IP {
+
+	ip := RemoteIP(r)
+	if ip == nil || !trusted(ip, trustedProxies) {
+		return ip
+	}
+
+	hops := forwardedFor(r)
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := parseIP(hops[i])
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if !trusted(ip, trustedProxies) {
+			break
+		}
+	}
+
+	return ip
+}
+
+// ParseTrustedProxies parses a list of IP addresses or CIDR networks for use
+// with ClientIP.
+func ParseTrustedProxies(proxies ...string) ([]*net.IPNet, error) {
+
+	networks := make([]*net.IPNet, 0, len(proxies))
+
+	for _, p := range proxies {
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil && ip.To4() != nil {
+				p += "/32"
+			} else {
+				p += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, err
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+// trusted returns true if the IP address is in one of the networks.
+func trusted(ip net.IP, networks []*net.IPNet) bool {
+	for _, n := range networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor returns the chain of client addresses added by proxies, oldest
+// first. The Forwarded header is used in preference to X-Forwarded-For.
+func forwardedFor(r *http.Request) []string {
+
+	hops := []string{}
+
+	if values := r.Header["Forwarded"]; len(values) > 0 {
+		for _, element := range strings.Split(strings.Join(values, ","), ",") {
+			hop := ""
+			for _, pair := range strings.Split(element, ";") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+					hop = strings.Trim(kv[1], `"`)
+				}
+			}
+			hops = append(hops, forwardedHost(hop))
+		}
+		return hops
+	}
+
+	for _, value := range r.Header["X-Forwarded-For"] {
+		for _, hop := range strings.Split(value, ",") {
+			hops = append(hops, forwardedHost(strings.TrimSpace(hop)))
+		}
+	}
+
+	return hops
+}
+
+// forwardedHost strips the port from an address in a Forwarded header, which
+// may be of the form "192.0.2.1", "192.0.2.1:1234", "[2001:db8::1]" or
+// "[2001:db8::1]:1234".
+func forwardedHost(hop string) string {
+
+	if strings.HasPrefix(hop, "[") {
+		if i := strings.Index(hop, "]"); i > 0 {
+			return hop[1:i]
+		}
+		return hop
+	}
+
+	if strings.Count(hop, ":") == 1 {
+		return hop[:strings.Index(hop, ":")]
+	}
+
+	return hop
+}
+
+// parseIP parses an IP address, ignoring the zone of link-local IPv6
+// addresses.
+func parseIP(host string) net.IP {
+
+	if i := strings.LastIndex(host, "%"); i >= 0 {
+		host = host[:i]
+	}
+
+	return net.ParseIP(host)
+}
@@ -0,0 +1,61 @@
+package octokey
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRemoteIP(t *testing.T) {
+
+	for addr, expected := range map[string]string{
+		"192.0.2.1:1234":        "192.0.2.1",
+		"[2001:db8::1]:1234":    "2001:db8::1",
+		"[fe80::1%eth0]:1234":   "fe80::1",
+		"192.0.2.1":             "192.0.2.1",
+		"not an ip address:123": "<nil>",
+	} {
+		r := &http.Request{RemoteAddr: addr}
+		if actual := RemoteIP(r).String(); actual != expected {
+			t.Error(addr, actual, "!=", expected)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+
+	proxies, err := ParseTrustedProxies("10.0.0.0/8", "2001:db8::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		remoteAddr string
+		header     string
+		value      string
+		expected   string
+		comment    string
+	}{
+		{"192.0.2.1:1234", "", "", "192.0.2.1", "no proxy"},
+		{"192.0.2.1:1234", "X-Forwarded-For", "198.51.100.1", "192.0.2.1", "untrusted proxy"},
+		{"10.0.0.1:1234", "X-Forwarded-For", "198.51.100.1", "198.51.100.1", "trusted proxy"},
+		{"10.0.0.1:1234", "X-Forwarded-For", "203.0.113.7, 198.51.100.1, 10.0.0.2", "198.51.100.1", "spoofed address before the client"},
+		{"10.0.0.1:1234", "X-Forwarded-For", "10.0.0.3, 10.0.0.2", "10.0.0.3", "only trusted proxies"},
+		{"10.0.0.1:1234", "X-Forwarded-For", "garbage", "10.0.0.1", "unparseable address"},
+		{"10.0.0.1:1234", "", "", "10.0.0.1", "trusted proxy without header"},
+		{"10.0.0.1:1234", "Forwarded", `for=198.51.100.1;proto=https, for="[2001:db8::1]:4711"`, "198.51.100.1", "Forwarded header"},
+		{"[2001:db8::1]:1234", "Forwarded", `for="[2001:db8::2]"`, "2001:db8::2", "IPv6 trusted proxy"},
+		{"10.0.0.1:1234", "Forwarded", `for=198.51.100.1:1234`, "198.51.100.1", "Forwarded with port"},
+		{"10.0.0.1:1234", "Forwarded", `for=unknown`, "10.0.0.1", "obfuscated Forwarded"},
+	}
+
+	for _, test := range tests {
+		r := &http.Request{RemoteAddr: test.remoteAddr, Header: http.Header{}}
+		if test.header != "" {
+			r.Header.Set(test.header, test.value)
+		}
+
+		if actual := ClientIP(r, proxies).String(); actual != test.expected {
+			t.Error(test.comment, actual, "!=", test.expected)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package octokey
+
+import (
+	"sync"
+	"time"
+)
+
+// A Clock tells the time. The Octokey uses it to timestamp and validate
+// challenges, so that tests can control time.
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is a Clock that uses time.Now()
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// A FakeClock is a Clock for tests that only moves when you tell it to. It is
+// safe to use from multiple goroutines.
+type FakeClock struct {
+	t     time.Time
+	mutex sync.Mutex
+}
+
+// NewFakeClock creates a FakeClock stopped at the given time.
+func NewFakeClock(t time.Time) *FakeClock {
+	return &FakeClock{t: t}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.t
+}
+
+// Set stops the clock at the given time.
+func (c *FakeClock) Set(t time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.t = t
+}
+
+// Advance moves the clock forward by the given duration.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.t = c.t.Add(d)
+}
+
+// now returns the current time according to the Clock, or time.Now() if
+// there is none.
+func (O *Octokey) now() time.Time {
+	return clockOrDefault(O.Clock).Now()
+}
+
+// clockOrDefault returns the clock, or the real clock if it is nil.
+func clockOrDefault(c Clock) Clock {
+	if c == nil {
+		return realClock{}
+	}
+	return c
+}
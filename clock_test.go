@@ -0,0 +1,43 @@
+package octokey
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestClockPerInstance(t *testing.T) {
+
+	clientIp := net.ParseIP("127.0.0.1")
+
+	for _, year := range []int{2012, 2024, 2040} {
+		year := year
+		t.Run(time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC).Format("2006"), func(t *testing.T) {
+			t.Parallel()
+
+			clock := NewFakeClock(time.Date(year, 7, 12, 22, 12, 58, 0, time.UTC))
+			O := &Octokey{ChallengeSecret: []byte("hello world"), Clock: clock}
+
+			c, err := O.NewChallenge(clientIp)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			challenge := Challenge{O: O}
+			challenge.ReadFrom(c, clientIp)
+			if !challenge.Timestamp.Equal(clock.Now()) {
+				t.Error(challenge.Timestamp, "!=", clock.Now())
+			}
+
+			clock.Advance(4 * time.Minute)
+			if err := O.ValidateChallenge(c, clientIp); err != nil {
+				t.Error(err)
+			}
+
+			clock.Advance(2 * time.Minute)
+			if err := O.ValidateChallenge(c, clientIp); err == nil {
+				t.Error("expired challenge was valid")
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package octokey
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"github.com/octokey/octokey-go/buffer"
+)
+
+// An EcdsaKey is an ECDSA public key on the NIST P-256 curve.
+type EcdsaKey ecdsa.PublicKey
+
+const (
+	KEY_TYPE_ECDSA_NISTP256 = "ecdsa-sha2-nistp256"
+	ECDSA_CURVE_NISTP256    = "nistp256"
+)
+
+func (k *EcdsaKey) Type() string {
+	return KEY_TYPE_ECDSA_NISTP256
+}
+
+// WriteBuffer writes the public key to a buffer.
+func (k *EcdsaKey) WriteBuffer(b *buffer.Buffer) {
+	b.AddString(KEY_TYPE_ECDSA_NISTP256)
+	b.AddString(ECDSA_CURVE_NISTP256)
+	b.AddVarBytes(elliptic.Marshal(k.Curve, k.X, k.Y))
+}
+
+// readBody reads the public key from a buffer after its type.
+func (k *EcdsaKey) readBody(b *buffer.Buffer) error {
+
+	curve := b.ScanString()
+	point := b.ScanVarBytes()
+
+	if b.Error != nil || curve != ECDSA_CURVE_NISTP256 {
+		return ErrPublicKeyFormat
+	}
+
+	x, y := elliptic.Unmarshal(elliptic.P256(), point)
+	if x == nil {
+		return ErrPublicKeyFormat
+	}
+
+	k.Curve = elliptic.P256()
+	k.X = x
+	k.Y = y
+
+	return nil
+}
+
+// Verify checks an ecdsa-sha2-nistp256 signature of the data. The signature
+// is the two mpints r and s.
+func (k *EcdsaKey) Verify(algorithm string, data []byte, sig []byte) error {
+
+	if algorithm != SIGNING_ALGORITHM_ECDSA_SHA2_NISTP256 {
+		return ErrKeySignatureAlgorithm
+	}
+
+	s := &buffer.Buffer{Buffer: *bytes.NewBuffer(sig)}
+	r := s.ScanMPInt()
+	t := s.ScanMPInt()
+	s.ScanEof()
+
+	if s.Error != nil {
+		return ErrKeySignatureInvalid
+	}
+
+	digest := sha256.Sum256(data)
+
+	if !ecdsa.Verify((*ecdsa.PublicKey)(k), digest[:], r, t) {
+		return ErrKeySignatureInvalid
+	}
+
+	return nil
+}
+
+func (k *EcdsaKey) Equal(l Key) bool {
+	return keysEqual(k, l)
+}
+
+func (k *EcdsaKey) Fingerprint() string {
+	return keyFingerprint(k)
+}
+
+func (k *EcdsaKey) String() string {
+	return keyString(k)
+}
@@ -0,0 +1,61 @@
+package octokey
+
+import (
+	"crypto/ed25519"
+	"github.com/octokey/octokey-go/buffer"
+)
+
+// An Ed25519Key is an Ed25519 public key.
+type Ed25519Key ed25519.PublicKey
+
+const KEY_TYPE_ED25519 = "ssh-ed25519"
+
+func (k Ed25519Key) Type() string {
+	return KEY_TYPE_ED25519
+}
+
+// WriteBuffer writes the public key to a buffer.
+func (k Ed25519Key) WriteBuffer(b *buffer.Buffer) {
+	b.AddString(KEY_TYPE_ED25519)
+	b.AddVarBytes(k)
+}
+
+// readBody reads the public key from a buffer after its type.
+func (k *Ed25519Key) readBody(b *buffer.Buffer) error {
+
+	key := b.ScanVarBytes()
+
+	if b.Error != nil || len(key) != ed25519.PublicKeySize {
+		return ErrPublicKeyFormat
+	}
+
+	*k = key
+
+	return nil
+}
+
+// Verify checks an ssh-ed25519 signature of the data.
+func (k Ed25519Key) Verify(algorithm string, data []byte, sig []byte) error {
+
+	if algorithm != SIGNING_ALGORITHM_SSH_ED25519 {
+		return ErrKeySignatureAlgorithm
+	}
+
+	if len(sig) != ed25519.SignatureSize || !ed25519.Verify(ed25519.PublicKey(k), data, sig) {
+		return ErrKeySignatureInvalid
+	}
+
+	return nil
+}
+
+func (k Ed25519Key) Equal(l Key) bool {
+	return keysEqual(k, l)
+}
+
+func (k Ed25519Key) Fingerprint() string {
+	return keyFingerprint(k)
+}
+
+func (k Ed25519Key) String() string {
+	return keyString(k)
+}
@@ -0,0 +1,21 @@
+package octokey
+
+import (
+	"fmt"
+	"strings"
+)
+
+// malformed wraps an error from the buffer package, or from reading a key, so
+// that it can be identified with errors.Is(err, kind) as well as by its cause.
+func malformed(kind error, err error) error {
+	return fmt.Errorf("%w: %w", kind, err)
+}
+
+// joinErrors formats a list of reasons after a summary error.
+func joinErrors(summary error, errs []error) string {
+	reasons := make([]string, len(errs))
+	for i, err := range errs {
+		reasons[i] = err.Error()
+	}
+	return summary.Error() + " (" + strings.Join(reasons, "; ") + ")"
+}
@@ -0,0 +1,69 @@
+package octokey
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestChallengeErrors(t *testing.T) {
+
+	date := time.Date(2012, 7, 12, 22, 12, 58, 700000000, time.UTC)
+	O := &Octokey{ChallengeSecret: []byte("12345"), Clock: NewFakeClock(date)}
+
+	// Everything wrong at once, from the TSV in challenge_test.go
+	s := "AwAAATh9IfH7BH8AAAIAAAAFMTIzNDUAAAAUK7ovSBPpGjeqh5L8IVZMXg6gxsA="
+
+	err := O.ValidateChallenge(s, net.ParseIP("127.0.0.1"))
+
+	var challengeErr *ChallengeError
+	if !errors.As(err, &challengeErr) || len(challengeErr.Errors) != 4 {
+		t.Fatal("expected a ChallengeError with 4 reasons, got", err)
+	}
+
+	for _, expected := range []error{ErrChallengeInvalid, ErrChallengeHmacMismatch, ErrChallengeIpMismatch, ErrChallengeRandomMismatch, ErrChallengeExpired} {
+		if !errors.Is(err, expected) {
+			t.Error(err, "is not", expected)
+		}
+	}
+
+	for _, unexpected := range []error{ErrChallengeNotYetValid, ErrChallengeVersionMismatch, ErrChallengeMalformed} {
+		if errors.Is(err, unexpected) {
+			t.Error(err, "is", unexpected)
+		}
+	}
+
+	err = O.ValidateChallenge(s[:len(s)-8], net.ParseIP("127.0.0.1"))
+	if !errors.Is(err, ErrChallengeMalformed) || !errors.Is(err, ErrChallengeInvalid) {
+		t.Error("expected ErrChallengeMalformed, got", err)
+	}
+}
+
+func TestAuthRequestErrors(t *testing.T) {
+
+	O := &Octokey{ChallengeSecret: []byte("hello world")}
+	clientIp := net.ParseIP("127.0.0.1")
+
+	c, err := O.NewChallenge(clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := O.SignChallenge(c, "https://example.com/login", newTestSigner(t, "conrad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = O.VerifyAuthRequest(s, "https://example.com/other", net.ParseIP("127.0.0.2"))
+
+	for _, expected := range []error{ErrAuthRequestInvalid, ErrAuthRequestUrlMismatch, ErrChallengeIpMismatch} {
+		if !errors.Is(err, expected) {
+			t.Error(err, "is not", expected)
+		}
+	}
+
+	if errors.Is(err, ErrAuthRequestSignatureInvalid) {
+		t.Error(err, "is", ErrAuthRequestSignatureInvalid)
+	}
+}
@@ -0,0 +1,86 @@
+package octokey
+
+import (
+	"errors"
+	"net"
+)
+
+// An IpBinding controls how closely the IP address of the client that uses a
+// challenge must match the IP address it was issued to.
+type IpBinding int
+
+const (
+	// The IP addresses must be the same
+	IP_BINDING_EXACT IpBinding = iota
+	// The IP addresses must be in the same network, see Ipv4PrefixLength and
+	// Ipv6PrefixLength
+	IP_BINDING_PREFIX
+	// Challenges may be used from any IP address
+	IP_BINDING_NONE
+)
+
+const (
+	// The default size of an IPv4 network for IP_BINDING_PREFIX (bits)
+	IPV4_PREFIX_LENGTH = 24
+	// The default size of an IPv6 network for IP_BINDING_PREFIX (bits)
+	IPV6_PREFIX_LENGTH = 64
+)
+
+var (
+	ErrPolicyIpBinding = errors.New("octokey/policy: invalid IpBinding")
+)
+
+// checkIpBinding returns an error if the IP binding policy is invalid.
+func (O *Octokey) checkIpBinding() error {
+
+	switch O.IpBinding {
+	case IP_BINDING_EXACT, IP_BINDING_NONE:
+		return nil
+	case IP_BINDING_PREFIX:
+		if O.ipv4PrefixLength() < 1 || O.ipv4PrefixLength() > 32 ||
+			O.ipv6PrefixLength() < 1 || O.ipv6PrefixLength() > 128 {
+			return ErrPolicyIpBinding
+		}
+		return nil
+	}
+
+	return ErrPolicyIpBinding
+}
+
+// ipMatches returns true if the client IP is close enough to the IP in the
+// challenge.
+func (O *Octokey) ipMatches(challengeIp net.IP, clientIp net.IP) bool {
+
+	switch O.IpBinding {
+	case IP_BINDING_NONE:
+		return true
+	case IP_BINDING_PREFIX:
+		if challengeIp.To4() != nil && clientIp.To4() != nil {
+			mask := net.CIDRMask(O.ipv4PrefixLength(), 32)
+			return challengeIp.To4().Mask(mask).Equal(clientIp.To4().Mask(mask))
+		}
+		if challengeIp.To4() == nil && clientIp.To4() == nil && clientIp.To16() != nil {
+			mask := net.CIDRMask(O.ipv6PrefixLength(), 128)
+			return challengeIp.To16().Mask(mask).Equal(clientIp.To16().Mask(mask))
+		}
+		return false
+	}
+
+	return challengeIp.Equal(clientIp)
+}
+
+// ipv4PrefixLength returns the size of IPv4 networks for IP_BINDING_PREFIX.
+func (O *Octokey) ipv4PrefixLength() int {
+	if O.Ipv4PrefixLength == 0 {
+		return IPV4_PREFIX_LENGTH
+	}
+	return O.Ipv4PrefixLength
+}
+
+// ipv6PrefixLength returns the size of IPv6 networks for IP_BINDING_PREFIX.
+func (O *Octokey) ipv6PrefixLength() int {
+	if O.Ipv6PrefixLength == 0 {
+		return IPV6_PREFIX_LENGTH
+	}
+	return O.Ipv6PrefixLength
+}
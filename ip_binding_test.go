@@ -0,0 +1,59 @@
+package octokey
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIpBinding(t *testing.T) {
+
+	exact := &Octokey{ChallengeSecret: []byte("hello world")}
+	prefix := &Octokey{ChallengeSecret: []byte("hello world"), IpBinding: IP_BINDING_PREFIX}
+	prefix16 := &Octokey{ChallengeSecret: []byte("hello world"), IpBinding: IP_BINDING_PREFIX, Ipv4PrefixLength: 16, Ipv6PrefixLength: 48}
+	none := &Octokey{ChallengeSecret: []byte("hello world"), IpBinding: IP_BINDING_NONE}
+
+	tests := []struct {
+		O        *Octokey
+		issued   string
+		used     string
+		expected bool
+	}{
+		{exact, "192.0.2.1", "192.0.2.1", true},
+		{exact, "192.0.2.1", "192.0.2.2", false},
+		{prefix, "192.0.2.1", "192.0.2.200", true},
+		{prefix, "192.0.2.1", "192.0.3.1", false},
+		{prefix16, "192.0.2.1", "192.0.3.1", true},
+		{prefix, "2001:db8:0:1:aaaa::1", "2001:db8:0:1:bbbb::2", true},
+		{prefix, "2001:db8:0:1::1", "2001:db8:0:2::1", false},
+		{prefix16, "2001:db8:0:1::1", "2001:db8:0:2::1", true},
+		{prefix, "192.0.2.1", "::ffff:192.0.2.1", true},
+		{prefix, "192.0.2.1", "2001:db8::1", false},
+		{none, "192.0.2.1", "2001:db8::1", true},
+	}
+
+	for _, test := range tests {
+		c, err := test.O.NewChallenge(net.ParseIP(test.issued))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = test.O.ValidateChallenge(c, net.ParseIP(test.used))
+		if (err == nil) != test.expected {
+			t.Error(test.O.IpBinding, test.issued, test.used, err)
+		}
+	}
+
+	if _, err := none.NewChallenge(nil); err != nil {
+		t.Error(err)
+	}
+
+	for _, O := range []*Octokey{
+		{IpBinding: 7},
+		{IpBinding: IP_BINDING_PREFIX, Ipv4PrefixLength: 33},
+		{IpBinding: IP_BINDING_PREFIX, Ipv6PrefixLength: -1},
+	} {
+		if err := O.CheckPolicy(); err != ErrPolicyIpBinding {
+			t.Error("expected ErrPolicyIpBinding, got", err)
+		}
+	}
+}
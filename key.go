@@ -0,0 +1,135 @@
+package octokey
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/octokey/octokey-go/buffer"
+	"strings"
+)
+
+// A Key is a public key that a client can log in with. It is a *PublicKey,
+// an Ed25519Key or an *EcdsaKey.
+type Key interface {
+	// Type returns the ssh name of the key type, for example "ssh-ed25519".
+	Type() string
+	// WriteBuffer writes the key to a buffer in ssh wire format.
+	WriteBuffer(b *buffer.Buffer)
+	// Verify checks that sig is a signature of the data by the key, made
+	// with the given signing algorithm.
+	Verify(algorithm string, data []byte, sig []byte) error
+	// Equal returns true if both keys are the same.
+	Equal(k Key) bool
+	// Fingerprint returns the SHA256 fingerprint of the key in the same
+	// format as ssh-keygen -l.
+	Fingerprint() string
+	// String returns the key in the same format as used by ssh.
+	String() string
+}
+
+var (
+	ErrKeyType               = errors.New("octokey/key: unsupported key type")
+	ErrKeySignatureAlgorithm = errors.New("octokey/key: signing algorithm not supported by key")
+	ErrKeySignatureInvalid   = errors.New("octokey/key: signature invalid")
+)
+
+// ReadKey reads a public key of any supported type from a buffer.
+func ReadKey(b *buffer.Buffer) (Key, error) {
+
+	t := b.ScanString()
+
+	if b.Error != nil {
+		return nil, ErrPublicKeyFormat
+	}
+
+	switch t {
+	case PUBLIC_KEY_TYPE:
+		k := new(PublicKey)
+		if err := k.readBody(b); err != nil {
+			return nil, err
+		}
+		return k, nil
+
+	case KEY_TYPE_ED25519:
+		var k Ed25519Key
+		if err := k.readBody(b); err != nil {
+			return nil, err
+		}
+		return k, nil
+
+	case KEY_TYPE_ECDSA_NISTP256:
+		k := new(EcdsaKey)
+		if err := k.readBody(b); err != nil {
+			return nil, err
+		}
+		return k, nil
+	}
+
+	return nil, ErrKeyType
+}
+
+// ParseKey reads a public key of any supported type from a string in the
+// same format as used by ssh, for example "ssh-ed25519 AAAA... comment".
+func ParseKey(text string) (Key, error) {
+
+	fields := strings.Fields(text)
+	if len(fields) < 2 {
+		return nil, ErrPublicKeyFormat
+	}
+
+	b := buffer.NewBuffer(fields[1])
+	k, err := ReadKey(b)
+	if err != nil {
+		return nil, err
+	}
+
+	b.ScanEof()
+
+	if b.Error != nil {
+		return nil, b.Error
+	}
+
+	if k.Type() != fields[0] {
+		return nil, ErrPublicKeyFormat
+	}
+
+	return k, nil
+}
+
+// isKeyType returns true if keys of the type can be read by ReadKey.
+func isKeyType(t string) bool {
+	return t == PUBLIC_KEY_TYPE || t == KEY_TYPE_ED25519 || t == KEY_TYPE_ECDSA_NISTP256
+}
+
+// keyBuffer returns the key in ssh wire format.
+func keyBuffer(k Key) *buffer.Buffer {
+	b := new(buffer.Buffer)
+
+	k.WriteBuffer(b)
+
+	if b.Error != nil {
+		panic(errors.New("invalid public key: " + b.Error.Error()))
+	}
+
+	return b
+}
+
+// keysEqual returns true if both keys have the same ssh wire format.
+func keysEqual(k Key, l Key) bool {
+	if k == nil || l == nil {
+		return false
+	}
+	return bytes.Equal(keyBuffer(k).Raw(), keyBuffer(l).Raw())
+}
+
+// keyFingerprint is the SHA256 of the key in ssh wire format.
+func keyFingerprint(k Key) string {
+	digest := sha256.Sum256(keyBuffer(k).Raw())
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(digest[:])
+}
+
+// keyString is the key type followed by the key in ssh wire format.
+func keyString(k Key) string {
+	return k.Type() + " " + keyBuffer(k).String() + "\n"
+}
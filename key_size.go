@@ -0,0 +1,46 @@
+package octokey
+
+import (
+	"errors"
+)
+
+var (
+	ErrPolicyKeySize = errors.New("octokey/policy: MinKeySize or MaxKeySize out of range")
+)
+
+// checkKeySize returns an error if the key size policy is invalid.
+func (O *Octokey) checkKeySize() error {
+
+	if O.minKeySize() < SSH_RSA_MINIMUM_MODULUS_SIZE ||
+		O.maxKeySize() > SSH_RSA_MAXIMUM_MODULUS_SIZE ||
+		O.minKeySize() > O.maxKeySize() {
+		return ErrPolicyKeySize
+	}
+
+	return nil
+}
+
+// checkKey returns an error if the public key may not be used to log in.
+// Only the size of RSA keys can vary.
+func (O *Octokey) checkKey(key Key) error {
+	if k, ok := key.(*PublicKey); ok {
+		return k.CheckSize(O.minKeySize(), O.maxKeySize())
+	}
+	return nil
+}
+
+// minKeySize returns the size of the smallest modulus that is accepted.
+func (O *Octokey) minKeySize() int {
+	if O.MinKeySize == 0 {
+		return SSH_RSA_MINIMUM_MODULUS_SIZE
+	}
+	return O.MinKeySize
+}
+
+// maxKeySize returns the size of the largest modulus that is accepted.
+func (O *Octokey) maxKeySize() int {
+	if O.MaxKeySize == 0 {
+		return SSH_RSA_MAXIMUM_MODULUS_SIZE
+	}
+	return O.MaxKeySize
+}
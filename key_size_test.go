@@ -0,0 +1,73 @@
+package octokey
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"github.com/octokey/octokey-go/buffer"
+	"math/big"
+	"net"
+	"testing"
+)
+
+func TestPublicKeyCheckSize(t *testing.T) {
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		n   *big.Int
+		err error
+	}{
+		{key.N, nil},
+		{new(big.Int).Add(key.N, big.NewInt(1)), ErrPublicKeyModulusEven},
+		{new(big.Int).SetBit(new(big.Int).Rsh(key.N, 512), 0, 1), ErrPublicKeyTooSmall},
+		{new(big.Int).Add(new(big.Int).Lsh(key.N, 16384), big.NewInt(1)), ErrPublicKeyTooLarge},
+	}
+
+	for _, test := range tests {
+		b := new(buffer.Buffer)
+		b.AddString(PUBLIC_KEY_TYPE)
+		b.AddMPInt(big.NewInt(EXPONENT))
+		b.AddMPInt(test.n)
+
+		k := new(PublicKey)
+		err := k.ReadBuffer(buffer.NewBuffer(b.String()))
+		if !errors.Is(err, test.err) || (err == nil) != (test.err == nil) {
+			t.Error("expected", test.err, "got", err)
+		}
+	}
+}
+
+func TestMinKeySize(t *testing.T) {
+
+	clientIp := net.ParseIP("127.0.0.1")
+	O := &Octokey{ChallengeSecret: []byte("hello world"), MinKeySize: 2048}
+
+	c, err := O.NewChallenge(clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := O.SignChallenge(c, "https://example.com/login", newTestSigner(t, "conrad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = O.VerifyAuthRequest(s, "https://example.com/login", clientIp)
+	if !errors.Is(err, ErrPublicKeyTooSmall) || !errors.Is(err, ErrAuthRequestInvalid) {
+		t.Error("expected ErrPublicKeyTooSmall, got", err)
+	}
+
+	for _, O := range []*Octokey{
+		{MinKeySize: 512},
+		{MaxKeySize: 32768},
+		{MinKeySize: 4096, MaxKeySize: 2048},
+	} {
+		if err := O.CheckPolicy(); err != ErrPolicyKeySize {
+			t.Error("expected ErrPolicyKeySize, got", err)
+		}
+	}
+}
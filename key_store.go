@@ -0,0 +1,352 @@
+package octokey
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// A KeyStore keeps track of which public keys may be used to log in as
+// which user.
+type KeyStore interface {
+	// Keys returns all the public keys that may log in as the user.
+	Keys(username string) ([]Key, error)
+	// AddKey allows the public key to log in as the user.
+	AddKey(username string, key Key) error
+	// RemoveKey stops the public key from logging in as the user.
+	RemoveKey(username string, key Key) error
+}
+
+var (
+	ErrKeyStoreUsername = errors.New("octokey/key_store: invalid username")
+	ErrKeyStorePath     = errors.New("octokey/key_store: Path must contain %u")
+	ErrKeyNotAuthorized = errors.New("octokey/key_store: key not authorized")
+)
+
+// Authorize checks whether the key store allows the public key to log in as
+// the user, like IsAuthorized, and tells the RateLimiter and Observer the
+// outcome. Use it after VerifyAuthRequest: a valid auth request only counts as
+// a successful login, resetting the user's failures, once its key has been
+// authorized. A key that is not authorized is reported as a failure with
+// ErrKeyNotAuthorized.
+func (O *Octokey) Authorize(s KeyStore, clientIp net.IP, username string, key Key) (bool, error) {
+
+	ok, err := IsAuthorized(s, username, key)
+	if err != nil {
+		return false, err
+	}
+
+	if O.RateLimiter != nil {
+		if ok {
+			O.RateLimiter.AuthRequestSucceeded(clientIp, username)
+		} else {
+			O.RateLimiter.AuthRequestFailed(clientIp, username)
+		}
+	}
+
+	if !ok {
+		O.authRequestFailed(clientIp, username, ErrKeyNotAuthorized)
+	} else if O.Observer != nil {
+		O.Observer.AuthRequestSucceeded(clientIp, username, key.Fingerprint())
+	}
+
+	return ok, nil
+}
+
+// IsAuthorized checks whether the key store allows the public key to log in
+// as the user. Use it after VerifyAuthRequest, or use Octokey.Authorize if
+// there is a RateLimiter.
+func IsAuthorized(s KeyStore, username string, key Key) (bool, error) {
+
+	keys, err := s.Keys(username)
+	if err != nil {
+		return false, err
+	}
+
+	for _, k := range keys {
+		if k.Equal(key) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// A MemoryKeyStore is a KeyStore that keeps everything in memory.
+type MemoryKeyStore struct {
+	keys  map[string][]Key
+	mutex sync.Mutex
+}
+
+// NewMemoryKeyStore creates an empty MemoryKeyStore.
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{keys: make(map[string][]Key)}
+}
+
+func (s *MemoryKeyStore) Keys(username string) ([]Key, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	keys := make([]Key, len(s.keys[username]))
+	copy(keys, s.keys[username])
+
+	return keys, nil
+}
+
+func (s *MemoryKeyStore) AddKey(username string, key Key) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, k := range s.keys[username] {
+		if k.Equal(key) {
+			return nil
+		}
+	}
+
+	s.keys[username] = append(s.keys[username], key)
+
+	return nil
+}
+
+func (s *MemoryKeyStore) RemoveKey(username string, key Key) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	keys := make([]Key, 0, len(s.keys[username]))
+	for _, k := range s.keys[username] {
+		if !k.Equal(key) {
+			keys = append(keys, k)
+		}
+	}
+
+	if len(keys) == 0 {
+		delete(s.keys, username)
+	} else {
+		s.keys[username] = keys
+	}
+
+	return nil
+}
+
+// An AuthorizedKeysStore is a KeyStore that uses one OpenSSH-style
+// authorized_keys file per user. Path is the location of the file with
+// "%u" standing in for the username, for example "/home/%u/.ssh/authorized_keys".
+// A Path without "%u" would give every user the same keys, so it is rejected
+// with ErrKeyStorePath.
+//
+// Files are replaced when keys are added or removed. The new file keeps the
+// mode and, on Unix, the owner of the old one; new files are created with
+// mode 0600 and owned by the server process.
+//
+// Lines that do not contain a supported key are ignored when reading, and left
+// alone when adding or removing keys. Lines with options, such as from= or
+// command=, are also ignored because Octokey cannot enforce the restrictions
+// they place on the key; see ReadAuthorizedKeys.
+type AuthorizedKeysStore struct {
+	Path  string
+	mutex sync.Mutex
+}
+
+func (s *AuthorizedKeysStore) Keys(username string) ([]Key, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	content, err := s.read(username)
+	if err != nil {
+		return nil, err
+	}
+
+	return ReadAuthorizedKeys(bytes.NewReader(content))
+}
+
+func (s *AuthorizedKeysStore) AddKey(username string, key Key) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	content, err := s.read(username)
+	if err != nil {
+		return err
+	}
+
+	keys, err := ReadAuthorizedKeys(bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		if k.Equal(key) {
+			return nil
+		}
+	}
+
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		content = append(content, '\n')
+	}
+	content = append(content, key.String()...)
+
+	return s.write(username, content)
+}
+
+func (s *AuthorizedKeysStore) RemoveKey(username string, key Key) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	content, err := s.read(username)
+	if err != nil {
+		return err
+	}
+
+	// There is nothing to remove, and the file may not exist.
+	if len(content) == 0 {
+		return nil
+	}
+
+	out := new(bytes.Buffer)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+
+	for scanner.Scan() {
+		k := parseAuthorizedKey(scanner.Text())
+		if k == nil || !k.Equal(key) {
+			out.WriteString(scanner.Text() + "\n")
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return s.write(username, out.Bytes())
+}
+
+// path returns the location of the authorized_keys file for the user.
+func (s *AuthorizedKeysStore) path(username string) (string, error) {
+
+	if username == "" || username == "." || username == ".." ||
+		strings.ContainsAny(username, "/\\\x00") {
+		return "", ErrKeyStoreUsername
+	}
+
+	if !strings.Contains(s.Path, "%u") {
+		return "", ErrKeyStorePath
+	}
+
+	return strings.Replace(s.Path, "%u", username, -1), nil
+}
+
+// read returns the contents of the user's authorized_keys file, which
+// is empty if the file does not exist.
+func (s *AuthorizedKeysStore) read(username string) ([]byte, error) {
+
+	path, err := s.path(username)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return []byte{}, nil
+	}
+
+	return content, err
+}
+
+// write replaces the contents of the user's authorized_keys file. The new
+// contents are written to a temporary file first so that readers never see
+// a partial file, with the mode and owner of the existing file.
+func (s *AuthorizedKeysStore) write(username string, content []byte) error {
+
+	path, err := s.path(username)
+	if err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0600)
+	existing, err := os.Stat(path)
+	if err == nil {
+		mode = existing.Mode().Perm()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), ".authorized_keys")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(content)
+	if err == nil {
+		err = f.Chmod(mode)
+	}
+	if err == nil && existing != nil {
+		err = chownLike(f, existing)
+	}
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// ReadAuthorizedKeys reads all the supported keys from an OpenSSH-style
+// authorized_keys file. Comments, blank lines and other key types are
+// skipped.
+//
+// Keys with options are skipped too. Options like from="..." and
+// command="..." restrict what the key may do in OpenSSH, and treating the
+// key as unrestricted would grant more access than the file intends.
+func ReadAuthorizedKeys(r io.Reader) ([]Key, error) {
+
+	keys := []Key{}
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		k := parseAuthorizedKey(scanner.Text())
+		if k != nil {
+			keys = append(keys, k)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// parseAuthorizedKey reads the key from one line of an authorized_keys file.
+// Lines are of the form "[options] <type> <base64> [comment]". It returns nil
+// if the line has options, or does not contain a valid key of a supported
+// type.
+func parseAuthorizedKey(line string) Key {
+
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' {
+		return nil
+	}
+
+	fields := strings.Fields(line)
+
+	if len(fields) < 2 || !isKeyType(fields[0]) {
+		return nil
+	}
+
+	k, err := ParseKey(fields[0] + " " + fields[1])
+	if err != nil {
+		return nil
+	}
+
+	return k
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package octokey
+
+import (
+	"os"
+)
+
+// chownLike does nothing on systems without Unix file owners.
+func chownLike(f *os.File, existing os.FileInfo) error {
+	return nil
+}
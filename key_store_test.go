@@ -0,0 +1,118 @@
+package octokey
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testKeyStore(t *testing.T, s KeyStore) {
+
+	k1 := newTestSigner(t, "conrad").PublicKey()
+	k2 := newTestSigner(t, "conrad").PublicKey()
+
+	check := func(username string, key Key, expected bool) {
+		ok, err := IsAuthorized(s, username, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != expected {
+			t.Error(username, ok, "!=", expected)
+		}
+	}
+
+	check("conrad", k1, false)
+
+	for _, k := range []Key{k1, k2, k1} {
+		if err := s.AddKey("conrad", k); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys, err := s.Keys("conrad")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Error("expected 2 keys, got", len(keys))
+	}
+
+	check("conrad", k1, true)
+	check("conrad", k2, true)
+	check("martin", k1, false)
+
+	if err := s.RemoveKey("conrad", k1); err != nil {
+		t.Fatal(err)
+	}
+
+	check("conrad", k1, false)
+	check("conrad", k2, true)
+}
+
+func TestMemoryKeyStore(t *testing.T) {
+	testKeyStore(t, NewMemoryKeyStore())
+}
+
+func TestAuthorizedKeysStore(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "octokey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := &AuthorizedKeysStore{Path: filepath.Join(dir, "%u")}
+	testKeyStore(t, s)
+
+	if _, err := s.Keys("../conrad"); err != ErrKeyStoreUsername {
+		t.Error("expected ErrKeyStoreUsername, got", err)
+	}
+
+	shared := &AuthorizedKeysStore{Path: filepath.Join(dir, "authorized_keys")}
+	if _, err := shared.Keys("conrad"); err != ErrKeyStorePath {
+		t.Error("expected ErrKeyStorePath, got", err)
+	}
+	if err := shared.AddKey("conrad", newTestSigner(t, "conrad").PublicKey()); err != ErrKeyStorePath {
+		t.Error("expected ErrKeyStorePath, got", err)
+	}
+
+	if err := os.Chmod(filepath.Join(dir, "conrad"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddKey("conrad", newTestSigner(t, "conrad").PublicKey()); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "conrad")); err != nil || info.Mode().Perm() != 0640 {
+		t.Error("expected the file mode to be kept", info.Mode(), err)
+	}
+
+	if err := s.RemoveKey("martin", newTestSigner(t, "martin").PublicKey()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "martin")); !os.IsNotExist(err) {
+		t.Error("expected RemoveKey not to create a file, got", err)
+	}
+}
+
+func TestReadAuthorizedKeys(t *testing.T) {
+
+	k1 := newTestSigner(t, "conrad").PublicKey()
+	k2 := newTestSigner(t, "conrad").PublicKey()
+
+	file := "# a comment\n\n" +
+		strings.TrimSpace(k1.String()) + " conrad@laptop\n" +
+		"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl\n" +
+		`no-pty,command="echo hi" ` + strings.TrimSpace(k2.String()) + "\n" +
+		"ssh-rsa not-base64!\n"
+
+	keys, err := ReadAuthorizedKeys(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 2 || !keys[0].Equal(k1) || keys[1].Type() != KEY_TYPE_ED25519 {
+		t.Error("expected the ssh-rsa key without options and the ssh-ed25519 key, got", keys)
+	}
+}
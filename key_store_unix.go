@@ -0,0 +1,25 @@
+//go:build unix
+
+package octokey
+
+import (
+	"os"
+	"syscall"
+)
+
+// chownLike gives the file the same owner and group as the existing file.
+func chownLike(f *os.File, existing os.FileInfo) error {
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	want, ok := existing.Sys().(*syscall.Stat_t)
+	have, ok2 := info.Sys().(*syscall.Stat_t)
+	if !ok || !ok2 || (want.Uid == have.Uid && want.Gid == have.Gid) {
+		return nil
+	}
+
+	return f.Chown(int(want.Uid), int(want.Gid))
+}
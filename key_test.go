@@ -0,0 +1,179 @@
+package octokey
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"github.com/octokey/octokey-go/buffer"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestParseKey(t *testing.T) {
+
+	tests := []struct {
+		text        string
+		keyType     string
+		fingerprint string
+	}{
+		{"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl",
+			KEY_TYPE_ED25519, "SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU"},
+		{"ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAAIbmlzdHAyNTYAAABBBDpbDZSF7zwfdr2iy8vSbxztoVc1A6P6PMGAnG0IkV874UfL39GlKot53sZC2fo8ggPwSB5zPuZcY03UiYpJJFo= root@vm",
+			KEY_TYPE_ECDSA_NISTP256, "SHA256:/KXfr7bhafi2B0mw9Tp0zgoun3oeYwQnactu0MQMnRM"},
+	}
+
+	for _, test := range tests {
+		k, err := ParseKey(test.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if k.Type() != test.keyType || k.Fingerprint() != test.fingerprint {
+			t.Error(k.Type(), k.Fingerprint(), "!=", test.keyType, test.fingerprint)
+		}
+
+		if !strings.HasPrefix(test.text, strings.TrimSpace(k.String())) {
+			t.Error(k.String(), "does not round trip")
+		}
+	}
+
+	for _, text := range []string{
+		"ssh-dss AAAAB3NzaC1kc3MAAAA=",
+		"ssh-rsa AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl",
+		"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAHOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9",
+		"ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAAIbmlzdHAyNTYAAABBBDpbDZSF7zwfdr2iy8vSbxztoVc1A6P6PMGAnG0IkV874UfL39GlKot53sZC2fo8ggPwSB5zPuZcY03UiYpJJFs=",
+	} {
+		if _, err := ParseKey(text); err == nil {
+			t.Error("parsed invalid key", text)
+		}
+	}
+}
+
+func TestKeyTypes(t *testing.T) {
+
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientIp := net.ParseIP("127.0.0.1")
+	O := &Octokey{ChallengeSecret: []byte("hello world")}
+
+	for _, key := range []crypto.Signer{ed25519Key, ecdsaKey} {
+
+		signer, err := NewSigner("conrad", key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c, err := O.NewChallenge(clientIp)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s, err := O.SignChallenge(c, "https://example.com/login", signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		a, err := O.ReadAuthRequest(s, "https://example.com/login", clientIp, nil)
+		if err != nil {
+			t.Fatal(signer.PublicKey().Type(), err)
+		}
+
+		if a.SigningAlgorithm != signer.PublicKey().Type() || !a.PublicKey.Equal(signer.PublicKey()) {
+			t.Error("wrong key or algorithm", a.SigningAlgorithm, a.PublicKey)
+		}
+
+		if _, err := signer.Sign(SIGNING_ALGORITHM_RSA_SHA2_256, []byte("data")); err != ErrKeySignatureAlgorithm {
+			t.Error("expected ErrKeySignatureAlgorithm, got", err)
+		}
+
+		keys := NewMemoryKeyStore()
+		keys.AddKey("conrad", signer.PublicKey())
+
+		if ok, err := IsAuthorized(keys, "conrad", a.PublicKey); !ok || err != nil {
+			t.Error("key not authorized", err)
+		}
+
+		// Signatures are checked
+		tampered := *a
+		tampered.RequestUrl = "https://example.com/other"
+		if tampered.verifySignature() != ErrAuthRequestSignatureInvalid {
+			t.Error("expected ErrAuthRequestSignatureInvalid")
+		}
+
+		ed25519Only := &Octokey{ChallengeSecret: []byte("hello world"), AcceptedSigningAlgorithms: []string{SIGNING_ALGORITHM_SSH_ED25519}}
+		c, err = O.NewChallenge(clientIp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err = O.SignChallenge(c, "https://example.com/login", signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, err = ed25519Only.VerifyAuthRequest(s, "https://example.com/login", clientIp)
+		if (err == nil) != (signer.PublicKey().Type() == KEY_TYPE_ED25519) {
+			t.Error(signer.PublicKey().Type(), err)
+		}
+	}
+
+	if _, err := NewKey(&ecdsaKey.PublicKey); err != nil {
+		t.Error(err)
+	}
+
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSigner("conrad", p384); err != ErrKeyType {
+		t.Error("expected ErrKeyType, got", err)
+	}
+}
+
+func TestEcdsaKeyMalformedSignature(t *testing.T) {
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := NewKey(&ecdsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mpints := func(ints ...[]byte) []byte {
+		b := new(buffer.Buffer)
+		for _, i := range ints {
+			b.AddVarBytes(i)
+		}
+		return b.Raw()
+	}
+
+	tests := []struct {
+		sig     []byte
+		comment string
+	}{
+		{mpints([]byte{0x00}, []byte{0x01}), "r is a lone 0x00"},
+		{mpints([]byte{0x01}, []byte{0x00}), "s is a lone 0x00"},
+		{mpints([]byte{0xff}, []byte{0x01}), "negative r"},
+		{mpints([]byte{0x01}), "missing s"},
+		{mpints([]byte{0x01}, []byte{0x01}, []byte{0x01}), "trailing bytes"},
+		{[]byte{}, "empty"},
+	}
+
+	for _, test := range tests {
+		if err := key.Verify(SIGNING_ALGORITHM_ECDSA_SHA2_NISTP256, []byte("data"), test.sig); err != ErrKeySignatureInvalid {
+			t.Error(test.comment, "expected ErrKeySignatureInvalid, got", err)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package octokey
+
+import (
+	"net"
+)
+
+// An Observer is told about authentication events, for example so that they
+// can be logged or sent to a monitoring system. Its methods are called
+// synchronously, so they should not block.
+type Observer interface {
+	// ChallengeIssued is called when a new challenge has been created and is
+	// about to be returned.
+	ChallengeIssued(clientIp net.IP, c *Challenge)
+	// ChallengeRejected is called when a challenge is invalid, either on its
+	// own or as part of an auth request. The error is a *ChallengeError.
+	ChallengeRejected(clientIp net.IP, err error)
+	// AuthRequestSucceeded is called by Authorize when a valid auth request's
+	// key is allowed to log in as the user.
+	AuthRequestSucceeded(clientIp net.IP, username string, fingerprint string)
+	// AuthRequestFailed is called when an auth request is invalid, the user
+	// is rate limited, or Authorize finds that the key is not allowed to log
+	// in as the user. The username is empty if the auth request could not be
+	// parsed.
+	AuthRequestFailed(clientIp net.IP, username string, err error)
+}
@@ -0,0 +1,126 @@
+package octokey
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+type recordingObserver struct {
+	events []string
+	errors []error
+}
+
+func (o *recordingObserver) ChallengeIssued(clientIp net.IP, c *Challenge) {
+	o.events = append(o.events, "issued "+clientIp.String())
+}
+
+func (o *recordingObserver) ChallengeRejected(clientIp net.IP, err error) {
+	o.events = append(o.events, "rejected "+clientIp.String())
+	o.errors = append(o.errors, err)
+}
+
+func (o *recordingObserver) AuthRequestSucceeded(clientIp net.IP, username string, fingerprint string) {
+	o.events = append(o.events, "succeeded "+username+" "+fingerprint)
+}
+
+func (o *recordingObserver) AuthRequestFailed(clientIp net.IP, username string, err error) {
+	o.events = append(o.events, "failed "+username)
+	o.errors = append(o.errors, err)
+}
+
+func TestObserver(t *testing.T) {
+
+	observer := &recordingObserver{}
+	O := &Octokey{ChallengeSecret: []byte("hello world"), Observer: observer}
+	signer := newTestSigner(t, "conrad")
+	fingerprint := signer.PublicKey().Fingerprint()
+	clientIp := net.ParseIP("192.0.2.1")
+
+	c, err := O.NewChallenge(clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	O.ValidateChallenge(c, net.ParseIP("192.0.2.2"))
+
+	s, err := O.SignChallenge(c, "https://example.com/login", signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := NewMemoryKeyStore()
+	keys.AddKey("conrad", signer.PublicKey())
+
+	username, key, err := O.VerifyAuthRequest(s, "https://example.com/login", clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	O.Authorize(keys, clientIp, username, key)
+	O.Authorize(keys, clientIp, username, newTestSigner(t, "conrad").PublicKey())
+	O.VerifyAuthRequest(s, "https://example.com/login", net.ParseIP("192.0.2.2"))
+	O.VerifyAuthRequest("garbage", "https://example.com/login", clientIp)
+
+	expected := []string{
+		"issued 192.0.2.1",
+		"rejected 192.0.2.2",
+		"succeeded conrad " + fingerprint,
+		"failed conrad",
+		"rejected 192.0.2.2",
+		"failed conrad",
+		"failed ",
+	}
+
+	if len(observer.events) != len(expected) {
+		t.Fatal(observer.events, "!=", expected)
+	}
+	for i := range expected {
+		if observer.events[i] != expected[i] {
+			t.Error(observer.events[i], "!=", expected[i])
+		}
+	}
+
+	if !errors.Is(observer.errors[0], ErrChallengeIpMismatch) || observer.errors[1] != ErrKeyNotAuthorized ||
+		!errors.Is(observer.errors[2], ErrChallengeIpMismatch) || !errors.Is(observer.errors[4], ErrAuthRequestMalformed) {
+		t.Error("unexpected errors", observer.errors)
+	}
+}
+
+// failingServerSigner is a ServerSigner that can't sign.
+type failingServerSigner struct {
+	Signer
+}
+
+func (s failingServerSigner) Sign(algorithm string, data []byte) ([]byte, error) {
+	return nil, errors.New("signing failed")
+}
+
+func TestObserverServerSignerFailure(t *testing.T) {
+
+	observer := &recordingObserver{}
+	O := &Octokey{
+		ChallengeSecret: []byte("hello world"),
+		Observer:        observer,
+		ServerSigner:    failingServerSigner{newTestSigner(t, "server")},
+	}
+
+	if _, err := O.NewChallenge(net.ParseIP("192.0.2.1")); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if len(observer.events) != 0 {
+		t.Error("challenge reported as issued", observer.events)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+
+	k, err := NewPublicKey("ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAAAgQDRJP0/3mczS49o5BLXariLAHMJUlnv41kYOhRAspK5Rta/gUmyjwvZZK9pdWAAtYHVLYr5/C7E7G4JLvL5VdNPeWHBbKYXUkN1mQEYgi3OYNG8jBpIUVET6dYnUPZkdGsKVvgAEYHZ0j8Ady+5a2Ebs/KhrnExwjTtsD0Yr9iUlQ==")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if k.Fingerprint() != "SHA256:uoDtFJmB3Zp3dlKu/wGlsRT3qqRBs75zAQNk4embENY" {
+		t.Error(k.Fingerprint())
+	}
+}
@@ -1,5 +1,121 @@
 package octokey
 
+import (
+	"sync"
+	"time"
+)
+
+// An Octokey issues challenges and verifies auth requests. Configure it by
+// setting its fields before use. An Octokey must not be copied after first
+// use, because it contains a lock around its secrets.
 type Octokey struct {
+	// ChallengeSecret is used to sign and validate challenges if no secrets
+	// have been added with AddSecret. After that it is only used to validate
+	// them, until it is retired.
 	ChallengeSecret []byte
+
+	// ChallengeStore is used to ensure that each challenge is only used once.
+	// If it is nil, challenges may be used as many times as you like until
+	// they expire.
+	ChallengeStore ChallengeStore
+
+	// MaxAge is how long a challenge is valid for. Defaults to MAX_AGE seconds.
+	MaxAge time.Duration
+
+	// MinAge is the minimum age of a valid challenge. It should be negative
+	// to allow for clock skew between servers. Defaults to MIN_AGE seconds,
+	// set it to NO_MIN_AGE for no minimum.
+	MinAge time.Duration
+
+	// RandomSize is the number of random bytes in each challenge. Defaults
+	// to RANDOM_SIZE.
+	RandomSize int
+
+	// HmacAlgorithm is the hash used to sign version 3 challenges: "sha1",
+	// "sha256" or "sha512". Defaults to HMAC_ALGORITHM. Version 4 and 5
+	// challenges are always signed with HMAC-SHA256.
+	HmacAlgorithm string
+
+	// ChallengeVersion is the version of new challenges. Defaults to
+	// CHALLENGE_VERSION.
+	ChallengeVersion uint8
+
+	// AcceptedVersions are the versions of challenge that are valid. It must
+	// include ChallengeVersion. Defaults to only ChallengeVersion. To migrate
+	// to version 4 without breaking challenges in flight, first accept both
+	// versions, then start issuing version 4, then stop accepting version 3.
+	// Version 5 is like version 4, but has room for a payload.
+	AcceptedVersions []uint8
+
+	// IpBinding controls how closely the IP address of the client using a
+	// challenge must match the one it was issued to. Defaults to
+	// IP_BINDING_EXACT.
+	IpBinding IpBinding
+
+	// Ipv4PrefixLength and Ipv6PrefixLength are the sizes of the networks
+	// that clients may move within when IpBinding is IP_BINDING_PREFIX.
+	// Default to IPV4_PREFIX_LENGTH and IPV6_PREFIX_LENGTH.
+	Ipv4PrefixLength int
+	Ipv6PrefixLength int
+
+	// MinKeySize and MaxKeySize are the sizes of the smallest and largest
+	// RSA moduli that may be used to log in (bits). Default to
+	// SSH_RSA_MINIMUM_MODULUS_SIZE and SSH_RSA_MAXIMUM_MODULUS_SIZE; 2048 is
+	// a good choice for MinKeySize.
+	MinKeySize int
+	MaxKeySize int
+
+	// SigningAlgorithm is the algorithm used by SignChallenge with RSA keys.
+	// Defaults to SIGNING_ALGORITHM, but SIGNING_ALGORITHM_RSA_SHA2_256
+	// should be used if the server supports it. Other keys always sign with
+	// the algorithm of the same name.
+	SigningAlgorithm string
+
+	// AcceptedSigningAlgorithms are the algorithms that auth requests may be
+	// signed with. Defaults to all supported algorithms. Set it to
+	// SIGNING_ALGORITHM_RSA_SHA2_256 and SIGNING_ALGORITHM_RSA_SHA2_512 to
+	// stop accepting SHA-1 signatures.
+	AcceptedSigningAlgorithms []string
+
+	// Audience binds challenges to one site, so that a challenge issued by
+	// one Octokey is not valid at another that shares its secret. It can be
+	// an origin like "https://example.com", in which case auth requests must
+	// also be for a url with that origin, or any other service id.
+	Audience string
+
+	// RateLimiter limits how many challenges clients can request, and how many
+	// invalid auth requests can be sent for each user. If it is nil, there
+	// are no limits.
+	RateLimiter RateLimiter
+
+	// Observer is told about authentication events. If it is nil, nothing is
+	// told.
+	Observer Observer
+
+	// SessionMaxAge is how long sessions created by NewSession are valid for.
+	// Defaults to SESSION_MAX_AGE seconds.
+	SessionMaxAge time.Duration
+
+	// SessionDenylist is used to revoke sessions before they expire. If it is
+	// nil, sessions can't be revoked.
+	SessionDenylist SessionDenylist
+
+	// ServerSigner signs new challenges so that clients can check that they
+	// came from this server. If it is nil, challenges are not signed.
+	ServerSigner ServerSigner
+
+	// ServerKey is the public key of the server, pinned by the client.
+	// SignChallenge refuses to sign challenges that were not signed by it.
+	// If it is nil, any challenge is signed.
+	ServerKey Key
+
+	// Clock is used to timestamp and validate challenges. Defaults to the
+	// real time.
+	Clock Clock
+
+	// secrets are used to sign and validate challenges, see AddSecret.
+	secrets                   []Secret
+	challengeSecretRetired    bool
+	challengeSecretSuperseded time.Time
+	mutex                     sync.RWMutex
 }
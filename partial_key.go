@@ -100,6 +100,11 @@ func NewPartialKey(text string) (*PartialKey, error) {
 	k.N = n
 	k.D = d
 
+	err := (*PublicKey)(&k.PublicKey).CheckSize(SSH_RSA_MINIMUM_MODULUS_SIZE, SSH_RSA_MAXIMUM_MODULUS_SIZE)
+	if err != nil {
+		return nil, err
+	}
+
 	return k, nil
 }
 
@@ -1,13 +1,17 @@
 package octokey
 
 import (
+	"crypto"
+	"crypto/rsa"
 	"errors"
+	"github.com/ConradIrwin/mrsa"
 	"io/ioutil"
 	"math/big"
 	"net/http"
 	"reflect"
 	"strings"
 )
+
 // A PartialSigner uses an escrow server to partially sign an mRSA request.
 // Internally it sends a SignRequest to the server and expects a SignRequest
 // response (which it could forward on to further servers if necessary)
@@ -16,6 +20,95 @@ type PartialSigner struct {
 	Key *PublicKey
 }
 
+var (
+	ErrPartialSignerNoUrls           = errors.New("octokey/partial_signer: no escrow urls")
+	ErrPartialSignerSignatureInvalid = errors.New("octokey/partial_signer: escrow servers did not produce a valid signature")
+)
+
+// An escrowSigner is a Signer that uses an mRSA key split between a local
+// PartialKey and escrow servers.
+type escrowSigner struct {
+	username string
+	local    *PartialKey
+	remote   []*PartialSigner
+}
+
+// NewEscrowSigner returns a Signer that signs auth requests for the user with
+// an mRSA key. The local partial key is combined with the partial signatures
+// from the escrow servers at each of the urls, so all of them are needed.
+func NewEscrowSigner(username string, key *PartialKey, urls ...string) (Signer, error) {
+
+	remote, err := partialSigners(key, urls)
+	if err != nil {
+		return nil, err
+	}
+
+	return &escrowSigner{username: username, local: key, remote: remote}, nil
+}
+
+func (s *escrowSigner) PublicKey() Key {
+	return (*PublicKey)(&s.local.PublicKey)
+}
+
+func (s *escrowSigner) Username() string {
+	return s.username
+}
+
+// Sign signs the data with one of the RSA signing algorithms.
+func (s *escrowSigner) Sign(algorithm string, data []byte) ([]byte, error) {
+
+	a, ok := signingAlgorithms[algorithm]
+	if !ok || a.keyType != PUBLIC_KEY_TYPE {
+		return nil, ErrKeySignatureAlgorithm
+	}
+
+	h := a.hash.New()
+	h.Write(data)
+
+	return signPKCS1v15(s.local, s.remote, a.hash, h.Sum(nil))
+}
+
+// partialSigners returns a PartialSigner for the key at each escrow url.
+func partialSigners(key *PartialKey, urls []string) ([]*PartialSigner, error) {
+
+	if len(urls) == 0 {
+		return nil, ErrPartialSignerNoUrls
+	}
+
+	remote := make([]*PartialSigner, len(urls))
+	for i, url := range urls {
+		remote[i] = &PartialSigner{Url: url, Key: (*PublicKey)(&key.PublicKey)}
+	}
+
+	return remote, nil
+}
+
+// signPKCS1v15 makes a PKCS#1 v1.5 signature of the digest by combining the
+// local partial key with the partial signatures from the escrow servers. A
+// misbehaving escrow server can't be detected until the parts are combined,
+// so the signature is checked before it is returned.
+func signPKCS1v15(local *PartialKey, remote []*PartialSigner, hash crypto.Hash, digest []byte) ([]byte, error) {
+
+	session := new(mrsa.Session)
+	session.PublicKey = local.PublicKey
+
+	for _, r := range remote {
+		session.Decryptors = append(session.Decryptors, r)
+	}
+	session.Decryptors = append(session.Decryptors, local)
+
+	sig, err := session.SignPKCS1v15(hash, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsa.VerifyPKCS1v15((*rsa.PublicKey)(&local.PublicKey), hash, digest, sig) != nil {
+		return nil, ErrPartialSignerSignatureInvalid
+	}
+
+	return sig, nil
+}
+
 // PartialDecrypt is used by the mrsa.Session to actually perform decryption.
 func (ps *PartialSigner) PartialDecrypt(c *big.Int) (*big.Int, error) {
 
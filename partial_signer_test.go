@@ -0,0 +1,93 @@
+package octokey
+
+import (
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestEscrow starts an escrow server that partially signs with the key.
+func newTestEscrow(t *testing.T, key *PartialKey) *httptest.Server {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		content, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		request, err := NewSignRequest(string(content))
+		if err == nil {
+			err = request.Sign(key)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Write([]byte(request.String()))
+	}))
+
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestEscrowSigner(t *testing.T) {
+
+	k1, k2, err := GeneratePartialKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	escrow := newTestEscrow(t, k2)
+	clientIp := net.ParseIP("127.0.0.1")
+
+	if _, err := NewEscrowSigner("conrad", k1); err != ErrPartialSignerNoUrls {
+		t.Error("expected ErrPartialSignerNoUrls, got", err)
+	}
+
+	signer, err := NewEscrowSigner("conrad", k1, escrow.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, algorithm := range []string{SIGNING_ALGORITHM_SSH_RSA, SIGNING_ALGORITHM_RSA_SHA2_256} {
+
+		O := &Octokey{ChallengeSecret: []byte("hello world"), SigningAlgorithm: algorithm}
+
+		c, err := O.NewChallenge(clientIp)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s, err := O.SignChallenge(c, "https://example.com/login", signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		username, key, err := O.VerifyAuthRequest(s, "https://example.com/login", clientIp)
+		if err != nil || username != "conrad" || !key.Equal((*PublicKey)(&k1.PublicKey)) {
+			t.Error(algorithm, username, err)
+		}
+	}
+
+	if _, err := signer.Sign(SIGNING_ALGORITHM_SSH_ED25519, []byte("data")); err != ErrKeySignatureAlgorithm {
+		t.Error("expected ErrKeySignatureAlgorithm, got", err)
+	}
+
+	// An escrow server with the wrong part of the key
+	wrong := &PartialKey{PublicKey: k2.PublicKey, D: new(big.Int).Add(k2.D, big.NewInt(1))}
+
+	bad, err := NewEscrowSigner("conrad", k1, newTestEscrow(t, wrong).URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := bad.Sign(SIGNING_ALGORITHM_SSH_RSA, []byte("data")); err != ErrPartialSignerSignatureInvalid {
+		t.Error("expected ErrPartialSignerSignatureInvalid, got", err)
+	}
+}
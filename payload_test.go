@@ -0,0 +1,77 @@
+package octokey
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestChallengePayload(t *testing.T) {
+
+	O := &Octokey{ChallengeSecret: []byte("hello world"), ChallengeVersion: 5}
+	clientIp := net.ParseIP("127.0.0.1")
+	payload := []byte("/repos/octokey/settings")
+
+	c, err := O.NewChallengeWithPayload(clientIp, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	challenge, err := O.ReadChallenge(c, clientIp, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(challenge.Payload, payload) {
+		t.Error(string(challenge.Payload), "!=", string(payload))
+	}
+	if challenge.String() != c {
+		t.Error(challenge.String(), "!=", c)
+	}
+
+	s, err := O.SignChallenge(c, "https://example.com/login", newTestSigner(t, "conrad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := O.ReadAuthRequest(s, "https://example.com/login", clientIp, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Username != "conrad" || !bytes.Equal(a.Challenge.Payload, payload) {
+		t.Error("payload not returned from auth request", a.Username, string(a.Challenge.Payload))
+	}
+
+	// Swap the payload without updating the HMAC
+	forged := Challenge{O: O}
+	forged.ReadFrom(c, clientIp)
+	forged.Payload = []byte("https://evil.example.com/")
+	b := forged.unsignedBuffer()
+	b.AddVarBytes(forged.Digest)
+
+	if err := O.ValidateChallenge(b.String(), clientIp); err == nil {
+		t.Error("challenge with modified payload was valid")
+	}
+
+	c, err = O.NewChallenge(clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge, err = O.ReadChallenge(c, clientIp, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(challenge.Payload) != 0 {
+		t.Error("unexpected payload", challenge.Payload)
+	}
+
+	if _, err := O.NewChallengeWithPayload(clientIp, make([]byte, MAX_PAYLOAD_SIZE+1)); err != ErrChallengePayloadTooLarge {
+		t.Error("expected ErrChallengePayloadTooLarge, got", err)
+	}
+
+	for _, version := range []uint8{3, 4} {
+		old := &Octokey{ChallengeSecret: []byte("hello world"), ChallengeVersion: version}
+		if _, err := old.NewChallengeWithPayload(clientIp, payload); err != ErrChallengePayloadUnsupported {
+			t.Error("expected ErrChallengePayloadUnsupported, got", err)
+		}
+	}
+}
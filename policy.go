@@ -0,0 +1,139 @@
+package octokey
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"hash"
+	"time"
+)
+
+// The smallest and largest RandomSize that may be configured (bytes)
+const (
+	MIN_RANDOM_SIZE = 16
+	MAX_RANDOM_SIZE = 1024
+)
+
+var (
+	ErrPolicyMaxAge        = errors.New("octokey/policy: MaxAge must be positive")
+	ErrPolicyMinAge        = errors.New("octokey/policy: MinAge must not be positive")
+	ErrPolicyRandomSize    = errors.New("octokey/policy: RandomSize out of range")
+	ErrPolicyHmacAlgorithm = errors.New("octokey/policy: unsupported HmacAlgorithm")
+	ErrPolicyVersion       = errors.New("octokey/policy: unsupported challenge version")
+)
+
+var hmacAlgorithms = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// CheckPolicy returns an error if the challenge policy configured on the
+// Octokey is not valid. NewChallenge and ValidateChallenge will fail with
+// the same error, so it's a good idea to call this when starting up.
+func (O *Octokey) CheckPolicy() error {
+
+	if O.maxAge() <= 0 {
+		return ErrPolicyMaxAge
+	}
+
+	if O.minAge() > 0 {
+		return ErrPolicyMinAge
+	}
+
+	if O.randomSize() < MIN_RANDOM_SIZE || O.randomSize() > MAX_RANDOM_SIZE {
+		return ErrPolicyRandomSize
+	}
+
+	if hmacAlgorithms[O.hmacAlgorithm()] == nil {
+		return ErrPolicyHmacAlgorithm
+	}
+
+	if !supportedVersion(O.challengeVersion()) || !O.acceptsVersion(O.challengeVersion()) {
+		return ErrPolicyVersion
+	}
+
+	for _, v := range O.AcceptedVersions {
+		if !supportedVersion(v) {
+			return ErrPolicyVersion
+		}
+	}
+
+	if err := O.checkIpBinding(); err != nil {
+		return err
+	}
+
+	if err := O.checkAudience(); err != nil {
+		return err
+	}
+
+	if err := O.checkKeySize(); err != nil {
+		return err
+	}
+
+	if err := O.checkSigningAlgorithms(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// maxAge returns the maximum age of a valid challenge.
+func (O *Octokey) maxAge() time.Duration {
+	if O.MaxAge == 0 {
+		return MAX_AGE * time.Second
+	}
+	return O.MaxAge
+}
+
+// minAge returns the minimum age of a valid challenge.
+func (O *Octokey) minAge() time.Duration {
+	if O.MinAge == 0 {
+		return MIN_AGE * time.Second
+	}
+	return O.MinAge
+}
+
+// randomSize returns the number of random bytes in a challenge.
+func (O *Octokey) randomSize() int {
+	if O.RandomSize == 0 {
+		return RANDOM_SIZE
+	}
+	return O.RandomSize
+}
+
+// hmacAlgorithm returns the name of the hash used to sign challenges.
+func (O *Octokey) hmacAlgorithm() string {
+	if O.HmacAlgorithm == "" {
+		return HMAC_ALGORITHM
+	}
+	return O.HmacAlgorithm
+}
+
+// challengeVersion returns the version of new challenges.
+func (O *Octokey) challengeVersion() uint8 {
+	if O.ChallengeVersion == 0 {
+		return CHALLENGE_VERSION
+	}
+	return O.ChallengeVersion
+}
+
+// acceptsVersion returns true if challenges of the given version are valid.
+func (O *Octokey) acceptsVersion(version uint8) bool {
+	if len(O.AcceptedVersions) == 0 {
+		return version == O.challengeVersion()
+	}
+	for _, v := range O.AcceptedVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// supportedVersion returns true if this package knows how to sign challenges
+// of the given version.
+func supportedVersion(version uint8) bool {
+	return version == CHALLENGE_VERSION_3 || version == CHALLENGE_VERSION_4 || version == CHALLENGE_VERSION_5
+}
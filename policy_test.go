@@ -0,0 +1,176 @@
+package octokey
+
+import (
+	"github.com/octokey/octokey-go/buffer"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestChallengePolicy(t *testing.T) {
+
+	clientIp := net.ParseIP("127.0.0.1")
+	issued := time.Date(2012, 7, 12, 22, 12, 58, 0, time.UTC)
+
+	clock := NewFakeClock(issued)
+	relaxed := &Octokey{ChallengeSecret: []byte("hello world"), Clock: clock}
+	strict := &Octokey{ChallengeSecret: []byte("hello world"), MaxAge: 60 * time.Second, MinAge: -5 * time.Second, Clock: clock}
+	unbounded := &Octokey{ChallengeSecret: []byte("hello world"), MinAge: NO_MIN_AGE, Clock: clock}
+
+	c, err := strict.NewChallenge(clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		O       *Octokey
+		offset  time.Duration
+		valid   bool
+		comment string
+	}{
+		{relaxed, 0, true, "relaxed, just issued"},
+		{relaxed, 2 * time.Minute, true, "relaxed, 2 minutes old"},
+		{relaxed, 6 * time.Minute, false, "relaxed, 6 minutes old"},
+		{relaxed, -20 * time.Second, true, "relaxed, 20 seconds in the future"},
+		{relaxed, -40 * time.Second, false, "relaxed, 40 seconds in the future"},
+		{strict, 0, true, "strict, just issued"},
+		{strict, 50 * time.Second, true, "strict, 50 seconds old"},
+		{strict, 2 * time.Minute, false, "strict, 2 minutes old"},
+		{strict, -20 * time.Second, false, "strict, 20 seconds in the future"},
+		{unbounded, -24 * time.Hour, true, "no minimum, a day in the future"},
+		{unbounded, 6 * time.Minute, false, "no minimum, 6 minutes old"},
+	}
+
+	for _, test := range tests {
+		clock.Set(issued.Add(test.offset))
+		err := test.O.ValidateChallenge(c, clientIp)
+		if (err == nil) != test.valid {
+			t.Error(test.comment, err)
+		}
+	}
+}
+
+func TestChallengePolicyRandomAndHmac(t *testing.T) {
+
+	clientIp := net.ParseIP("127.0.0.1")
+	O := &Octokey{ChallengeSecret: []byte("hello world"), RandomSize: 16, HmacAlgorithm: "sha256"}
+
+	c, err := O.NewChallenge(clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	challenge := Challenge{O: O}
+	challenge.ReadFrom(c, clientIp)
+
+	if len(challenge.Errors) > 0 {
+		t.Fatal(challenge.Errors)
+	}
+	if len(challenge.Random) != 16 || len(challenge.Digest) != 32 {
+		t.Error("policy not applied", len(challenge.Random), len(challenge.Digest))
+	}
+
+	other := &Octokey{ChallengeSecret: []byte("hello world")}
+	if err := other.ValidateChallenge(c, clientIp); err == nil {
+		t.Error("challenge valid with different policy")
+	}
+}
+
+func TestCheckPolicy(t *testing.T) {
+
+	tests := []struct {
+		O   *Octokey
+		err error
+	}{
+		{&Octokey{}, nil},
+		{&Octokey{MaxAge: -time.Second}, ErrPolicyMaxAge},
+		{&Octokey{MinAge: time.Second}, ErrPolicyMinAge},
+		{&Octokey{MinAge: NO_MIN_AGE}, nil},
+		{&Octokey{RandomSize: 8}, ErrPolicyRandomSize},
+		{&Octokey{RandomSize: 4096}, ErrPolicyRandomSize},
+		{&Octokey{HmacAlgorithm: "md5"}, ErrPolicyHmacAlgorithm},
+	}
+
+	for _, test := range tests {
+		if err := test.O.CheckPolicy(); err != test.err {
+			t.Error(err, "!=", test.err)
+		}
+	}
+
+	O := &Octokey{ChallengeSecret: []byte("hello world"), HmacAlgorithm: "md5"}
+	if _, err := O.NewChallenge(net.ParseIP("127.0.0.1")); err != ErrPolicyHmacAlgorithm {
+		t.Error("expected ErrPolicyHmacAlgorithm, got", err)
+	}
+}
+
+func TestChallengeVersionMigration(t *testing.T) {
+
+	clientIp := net.ParseIP("127.0.0.1")
+	secret := []byte("hello world")
+
+	v3 := &Octokey{ChallengeSecret: secret}
+	both := &Octokey{ChallengeSecret: secret, AcceptedVersions: []uint8{3, 4}}
+	v4 := &Octokey{ChallengeSecret: secret, ChallengeVersion: 4, AcceptedVersions: []uint8{3, 4}}
+	v4only := &Octokey{ChallengeSecret: secret, ChallengeVersion: 4}
+
+	c3, err := v3.NewChallenge(clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c4, err := v4.NewChallenge(clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	challenge := Challenge{O: v4}
+	challenge.ReadFrom(c4, clientIp)
+	if challenge.Version != 4 || len(challenge.Digest) != 32 {
+		t.Error("expected a version 4 challenge signed with sha256", challenge.Version, len(challenge.Digest))
+	}
+
+	// Version 4 challenges have the same fields as version 3, only the
+	// payload was added in version 5.
+	b := buffer.NewBuffer(c4)
+	b.ScanUint8()
+	b.ScanTimestamp()
+	b.ScanIP()
+	b.ScanVarBytes()
+	b.ScanVarBytes()
+	b.ScanEof()
+	if b.Error != nil {
+		t.Error("unexpected version 4 challenge layout", b.Error)
+	}
+
+	tests := []struct {
+		O       *Octokey
+		c       string
+		valid   bool
+		comment string
+	}{
+		{v3, c3, true, "v3 accepts v3"},
+		{v3, c4, false, "v3 rejects v4"},
+		{both, c3, true, "migrating accepts v3"},
+		{both, c4, true, "migrating accepts v4"},
+		{v4, c3, true, "v4 with fallback accepts v3"},
+		{v4only, c3, false, "v4 only rejects v3"},
+		{v4only, c4, true, "v4 only accepts v4"},
+	}
+
+	for _, test := range tests {
+		err := test.O.ValidateChallenge(test.c, clientIp)
+		if (err == nil) != test.valid {
+			t.Error(test.comment, err)
+		}
+	}
+
+	for _, O := range []*Octokey{
+		{ChallengeVersion: 6},
+		{ChallengeVersion: 4, AcceptedVersions: []uint8{3}},
+		{AcceptedVersions: []uint8{3, 9}},
+	} {
+		if err := O.CheckPolicy(); err != ErrPolicyVersion {
+			t.Error("expected ErrPolicyVersion, got", err)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package octokey
 
 import (
+	"crypto/rsa"
 	"errors"
+	"fmt"
 	"github.com/ConradIrwin/mrsa"
 	"github.com/octokey/octokey-go/buffer"
 	"math/big"
@@ -13,10 +15,19 @@ import (
 type PublicKey mrsa.PublicKey
 
 const PUBLIC_KEY_TYPE = "ssh-rsa"
-const SSH_RSA_MINIMUM_MODULUS_SIZE = 768
+
+// The smallest and largest moduli that will be parsed at all (bits). Use
+// MinKeySize and MaxKeySize on the Octokey to be stricter.
+const (
+	SSH_RSA_MINIMUM_MODULUS_SIZE = 768
+	SSH_RSA_MAXIMUM_MODULUS_SIZE = 16384
+)
 
 var (
-	ErrPublicKeyFormat = errors.New("octokey/public_key: invalid input")
+	ErrPublicKeyFormat      = errors.New("octokey/public_key: invalid input")
+	ErrPublicKeyModulusEven = errors.New("octokey/public_key: modulus is even")
+	ErrPublicKeyTooSmall    = errors.New("octokey/public_key: key too small")
+	ErrPublicKeyTooLarge    = errors.New("octokey/public_key: key too large")
 )
 
 // NewPublicKey reads the public key from a string.
@@ -58,32 +69,96 @@ func (p *PublicKey) WriteBuffer(b *buffer.Buffer) {
 func (p *PublicKey) ReadBuffer(b *buffer.Buffer) error {
 
 	t := b.ScanString()
-	e := b.ScanMPInt()
-	n := b.ScanMPInt()
 
 	if t != PUBLIC_KEY_TYPE {
 		return ErrPublicKeyFormat
 	}
 
-	if e.Cmp(big.NewInt(EXPONENT)) != 0 {
+	return p.readBody(b)
+}
+
+// readBody reads the public key from a buffer after its type.
+func (p *PublicKey) readBody(b *buffer.Buffer) error {
+
+	e := b.ScanMPInt()
+	n := b.ScanMPInt()
+
+	if b.Error != nil || e.Cmp(big.NewInt(EXPONENT)) != 0 {
 		return ErrPublicKeyFormat
 	}
 
 	p.E = EXPONENT
 	p.N = n
 
+	return p.CheckSize(SSH_RSA_MINIMUM_MODULUS_SIZE, SSH_RSA_MAXIMUM_MODULUS_SIZE)
+}
+
+func (p *PublicKey) Type() string {
+	return PUBLIC_KEY_TYPE
+}
+
+// Verify checks a PKCS#1 v1.5 signature of the data made with ssh-rsa,
+// rsa-sha2-256 or rsa-sha2-512.
+func (p *PublicKey) Verify(algorithm string, data []byte, sig []byte) error {
+
+	a, ok := signingAlgorithms[algorithm]
+	if !ok || a.keyType != PUBLIC_KEY_TYPE {
+		return ErrKeySignatureAlgorithm
+	}
+
+	h := a.hash.New()
+	h.Write(data)
+
+	err := rsa.VerifyPKCS1v15((*rsa.PublicKey)(p), a.hash, h.Sum(nil), sig)
+	if err != nil {
+		return ErrKeySignatureInvalid
+	}
+
 	return nil
 }
 
-// String returns the public key in the same format as used by ssh
-func (p *PublicKey) String() string {
-	b := new(buffer.Buffer)
+// Size returns the size of the modulus in bits.
+func (p *PublicKey) Size() int {
+	return p.N.BitLen()
+}
 
-	p.WriteBuffer(b)
+// CheckSize returns an error if the modulus is even, or if it has fewer than
+// min or more than max bits.
+func (p *PublicKey) CheckSize(min, max int) error {
 
-	if b.Error != nil {
-		panic(errors.New("invalid public key: " + b.Error.Error()))
+	if p.N == nil || p.N.Sign() <= 0 {
+		return ErrPublicKeyFormat
+	}
+
+	if p.N.Bit(0) == 0 {
+		return ErrPublicKeyModulusEven
+	}
+
+	if p.Size() < min {
+		return fmt.Errorf("%w: %d bits, need at least %d", ErrPublicKeyTooSmall, p.Size(), min)
+	}
+
+	if p.Size() > max {
+		return fmt.Errorf("%w: %d bits, need at most %d", ErrPublicKeyTooLarge, p.Size(), max)
 	}
 
-	return PUBLIC_KEY_TYPE + " " + b.String() + "\n"
+	return nil
+}
+
+// Equal returns true if both keys are RSA keys with the same exponent and
+// modulus.
+func (p *PublicKey) Equal(k Key) bool {
+	q, ok := k.(*PublicKey)
+	return ok && q != nil && p.E == q.E && p.N.Cmp(q.N) == 0
+}
+
+// Fingerprint returns the SHA256 fingerprint of the public key in the same
+// format as ssh-keygen -l, for example "SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8".
+func (p *PublicKey) Fingerprint() string {
+	return keyFingerprint(p)
+}
+
+// String returns the public key in the same format as used by ssh
+func (p *PublicKey) String() string {
+	return keyString(p)
 }
@@ -0,0 +1,234 @@
+package octokey
+
+import (
+	"errors"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// A RateLimiter protects the Octokey from clients that request too many
+// challenges or send too many invalid auth requests.
+type RateLimiter interface {
+	// AllowChallenge is called before issuing a challenge to a client. It
+	// returns a RateLimitError if the client has asked for too many.
+	AllowChallenge(clientIp net.IP) error
+	// AllowAuthRequest is called as soon as the username of an auth request
+	// has been read, before its signature is checked. It returns a
+	// RateLimitError if the user is locked out.
+	AllowAuthRequest(clientIp net.IP, username string) error
+	// AuthRequestFailed is called when an auth request for the user is
+	// invalid, or its key is not allowed to log in as the user.
+	AuthRequestFailed(clientIp net.IP, username string)
+	// AuthRequestSucceeded is called by Authorize when the user has logged in
+	// with a key they are allowed to use.
+	AuthRequestSucceeded(clientIp net.IP, username string)
+}
+
+var (
+	ErrRateLimited = errors.New("octokey/rate_limiter: rate limited")
+)
+
+// A RateLimitError is returned when a client is rate limited or a user is
+// locked out. It is always ErrRateLimited.
+type RateLimitError struct {
+	// Key is the client IP address, or the client IP address and username,
+	// that was limited
+	Key string
+	// RetryAfter is how long until the limit is lifted
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return ErrRateLimited.Error() + ": " + e.Key + " (retry after " + e.RetryAfter.String() + ")"
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+const (
+	// How many challenges a client can request per second by default
+	CHALLENGE_RATE = 1.0
+	// How many challenges a client can request in a burst by default
+	CHALLENGE_BURST = 20
+	// How many invalid auth requests a user can make per second by default
+	FAILURE_RATE = 1.0 / 60
+	// How many invalid auth requests a user can make in a burst by default
+	FAILURE_BURST = 5
+	// How long users are locked out for by default (seconds)
+	LOCKOUT = 15 * 60
+)
+
+// A MemoryRateLimiter is a RateLimiter that keeps token buckets in memory.
+// Each client IP address (or IPv6 /64 network) can request ChallengeBurst
+// challenges at once, and then ChallengeRate per second. Each user can have
+// FailureBurst invalid auth requests, and then FailureRate per second; beyond
+// that they are locked out for Lockout.
+//
+// Anyone can send an invalid auth request for any user, so lockouts can be
+// used to keep a user out. Choose a Lockout that is short enough to bear, or
+// set FailuresPerClient.
+type MemoryRateLimiter struct {
+	ChallengeRate  float64
+	ChallengeBurst int
+	FailureRate    float64
+	FailureBurst   int
+	Lockout        time.Duration
+
+	// FailuresPerClient counts failures for each user and client IP address
+	// (or IPv6 /64 network) separately, so that a user is only locked out
+	// from the clients that failed. An attacker with many addresses gets
+	// FailureBurst guesses from each.
+	FailuresPerClient bool
+
+	// Clock is used to refill the buckets. Defaults to the real time.
+	Clock Clock
+
+	challenges map[string]*bucket
+	failures   map[string]*bucket
+	nextSweep  time.Time
+	mutex      sync.Mutex
+}
+
+// A bucket holds tokens that are used up by requests and refilled over time.
+type bucket struct {
+	tokens      float64
+	updated     time.Time
+	lockedUntil time.Time
+}
+
+// How often a MemoryRateLimiter forgets about full buckets (seconds)
+const RATE_LIMITER_SWEEP_INTERVAL = 60
+
+// NewMemoryRateLimiter creates a MemoryRateLimiter with the default limits.
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		ChallengeRate:  CHALLENGE_RATE,
+		ChallengeBurst: CHALLENGE_BURST,
+		FailureRate:    FAILURE_RATE,
+		FailureBurst:   FAILURE_BURST,
+		Lockout:        LOCKOUT * time.Second,
+	}
+}
+
+func (l *MemoryRateLimiter) AllowChallenge(clientIp net.IP) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	key := rateLimitKey(clientIp)
+	b := l.bucket(&l.challenges, key, l.ChallengeRate, l.ChallengeBurst)
+
+	if b.tokens < 1 {
+		return &RateLimitError{Key: key, RetryAfter: refillTime(1-b.tokens, l.ChallengeRate)}
+	}
+
+	b.tokens--
+	return nil
+}
+
+func (l *MemoryRateLimiter) AllowAuthRequest(clientIp net.IP, username string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	key := l.failureKey(clientIp, username)
+	b := l.bucket(&l.failures, key, l.FailureRate, l.FailureBurst)
+
+	if b.lockedUntil.After(b.updated) {
+		return &RateLimitError{Key: key, RetryAfter: b.lockedUntil.Sub(b.updated)}
+	}
+
+	return nil
+}
+
+func (l *MemoryRateLimiter) AuthRequestFailed(clientIp net.IP, username string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	b := l.bucket(&l.failures, l.failureKey(clientIp, username), l.FailureRate, l.FailureBurst)
+
+	b.tokens--
+	if b.tokens < 1 {
+		b.lockedUntil = b.updated.Add(l.Lockout)
+		b.tokens = 0
+	}
+}
+
+func (l *MemoryRateLimiter) AuthRequestSucceeded(clientIp net.IP, username string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	delete(l.failures, l.failureKey(clientIp, username))
+}
+
+// bucket returns the bucket for the key, refilled up to the current time.
+func (l *MemoryRateLimiter) bucket(buckets *map[string]*bucket, key string, rate float64, burst int) *bucket {
+
+	currentTime := clockOrDefault(l.Clock).Now()
+
+	if *buckets == nil {
+		*buckets = make(map[string]*bucket)
+	}
+
+	if currentTime.After(l.nextSweep) {
+		l.sweep(l.challenges, currentTime, l.ChallengeRate, l.ChallengeBurst)
+		l.sweep(l.failures, currentTime, l.FailureRate, l.FailureBurst)
+		l.nextSweep = currentTime.Add(RATE_LIMITER_SWEEP_INTERVAL * time.Second)
+	}
+
+	b, ok := (*buckets)[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), updated: currentTime}
+		(*buckets)[key] = b
+	}
+
+	b.refill(currentTime, rate, burst)
+	return b
+}
+
+// sweep forgets about buckets that are full and not locked, as they are the
+// same as new buckets.
+func (l *MemoryRateLimiter) sweep(buckets map[string]*bucket, currentTime time.Time, rate float64, burst int) {
+	for k, b := range buckets {
+		b.refill(currentTime, rate, burst)
+		if b.tokens >= float64(burst) && !b.lockedUntil.After(currentTime) {
+			delete(buckets, k)
+		}
+	}
+}
+
+// refill adds the tokens earned since the bucket was last updated.
+func (b *bucket) refill(currentTime time.Time, rate float64, burst int) {
+	if currentTime.After(b.updated) {
+		b.tokens = math.Min(float64(burst), b.tokens+currentTime.Sub(b.updated).Seconds()*rate)
+		b.updated = currentTime
+	}
+}
+
+// refillTime returns how long it takes to earn the tokens.
+func refillTime(tokens float64, rate float64) time.Duration {
+	if rate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(math.Ceil(tokens / rate * float64(time.Second)))
+}
+
+// rateLimitKey groups IPv6 addresses by /64 network, as clients usually
+// control a whole network.
+func rateLimitKey(ip net.IP) string {
+	if ip.To4() == nil && ip.To16() != nil {
+		return ip.Mask(net.CIDRMask(64, 128)).String() + "/64"
+	}
+	return ip.String()
+}
+
+// failureKey identifies who failed auth requests are counted for: the user,
+// or the client and user if FailuresPerClient is set. The client comes first
+// as it never contains a space.
+func (l *MemoryRateLimiter) failureKey(clientIp net.IP, username string) string {
+	if l.FailuresPerClient {
+		return rateLimitKey(clientIp) + " " + username
+	}
+	return username
+}
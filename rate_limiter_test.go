@@ -0,0 +1,169 @@
+package octokey
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimitChallenges(t *testing.T) {
+
+	clock := NewFakeClock(time.Date(2012, 7, 12, 22, 12, 58, 0, time.UTC))
+	limiter := &MemoryRateLimiter{ChallengeRate: 1, ChallengeBurst: 3, Clock: clock}
+	O := &Octokey{ChallengeSecret: []byte("hello world"), RateLimiter: limiter, Clock: clock}
+
+	clientIp := net.ParseIP("192.0.2.1")
+
+	for i := 0; i < 3; i++ {
+		if _, err := O.NewChallenge(clientIp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := O.NewChallenge(clientIp)
+	var limited *RateLimitError
+	if !errors.As(err, &limited) || !errors.Is(err, ErrRateLimited) {
+		t.Fatal("expected a RateLimitError, got", err)
+	}
+	if limited.Key != "192.0.2.1" || limited.RetryAfter != time.Second {
+		t.Error("unexpected", limited)
+	}
+
+	if _, err := O.NewChallenge(net.ParseIP("192.0.2.2")); err != nil {
+		t.Error("other clients should not be limited", err)
+	}
+
+	clock.Advance(time.Second)
+	if _, err := O.NewChallenge(clientIp); err != nil {
+		t.Error("bucket was not refilled", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		O.NewChallenge(net.ParseIP("2001:db8::1"))
+	}
+	if _, err := O.NewChallenge(net.ParseIP("2001:db8::ffff")); !errors.Is(err, ErrRateLimited) {
+		t.Error("IPv6 clients should be limited by /64, got", err)
+	}
+}
+
+func TestRateLimitAuthRequests(t *testing.T) {
+
+	clock := NewFakeClock(time.Date(2012, 7, 12, 22, 12, 58, 0, time.UTC))
+	limiter := NewMemoryRateLimiter()
+	limiter.Clock = clock
+	store := NewMemoryChallengeStore()
+	store.Clock = clock
+	O := &Octokey{ChallengeSecret: []byte("hello world"), RateLimiter: limiter, ChallengeStore: store, Clock: clock}
+
+	signer := newTestSigner(t, "conrad")
+	clientIp := net.ParseIP("192.0.2.1")
+
+	sign := func(clientIp net.IP) string {
+		c, err := O.NewChallenge(clientIp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := O.SignChallenge(c, "https://example.com/login", signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	for i := 0; i < FAILURE_BURST; i++ {
+		_, _, err := O.VerifyAuthRequest(sign(clientIp), "https://example.com/other", clientIp)
+		if !errors.Is(err, ErrAuthRequestInvalid) {
+			t.Fatal("expected ErrAuthRequestInvalid, got", err)
+		}
+	}
+
+	s := sign(clientIp)
+	if _, _, err := O.VerifyAuthRequest(s, "https://example.com/login", clientIp); !errors.Is(err, ErrRateLimited) {
+		t.Fatal("expected the user to be locked out, got", err)
+	}
+
+	otherIp := net.ParseIP("192.0.2.2")
+	if _, _, err := O.VerifyAuthRequest(sign(otherIp), "https://example.com/login", otherIp); !errors.Is(err, ErrRateLimited) {
+		t.Error("expected the user to be locked out from other clients, got", err)
+	}
+
+	limiter.FailuresPerClient = true
+	if _, _, err := O.VerifyAuthRequest(sign(otherIp), "https://example.com/login", otherIp); err != nil {
+		t.Error("the user should not be locked out from other clients with FailuresPerClient", err)
+	}
+	limiter.FailuresPerClient = false
+
+	// The challenge was not used while the user was locked out
+	clock.Advance(LOCKOUT * time.Second)
+	O.MaxAge = 2 * LOCKOUT * time.Second
+	if _, _, err := O.VerifyAuthRequest(s, "https://example.com/login", clientIp); err != nil {
+		t.Fatal("lockout did not expire", err)
+	}
+}
+
+func TestRateLimitUnauthorizedKeys(t *testing.T) {
+
+	limiter := NewMemoryRateLimiter()
+	O := &Octokey{ChallengeSecret: []byte("hello world"), RateLimiter: limiter}
+
+	keys := NewMemoryKeyStore()
+	conrad := newTestSigner(t, "conrad")
+	keys.AddKey("conrad", conrad.PublicKey())
+
+	// The attacker signs as conrad with their own key, which is valid but not
+	// authorized, between guesses.
+	attacker := newTestSigner(t, "conrad")
+	clientIp := net.ParseIP("192.0.2.1")
+
+	for i := 0; i < 20; i++ {
+		c, err := O.NewChallenge(clientIp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := O.SignChallenge(c, "https://example.com/login", attacker)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		username, key, err := O.VerifyAuthRequest(s, "https://example.com/login", clientIp)
+		if errors.Is(err, ErrRateLimited) {
+			if i != FAILURE_BURST {
+				t.Error("locked out after", i, "attempts")
+			}
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := O.Authorize(keys, clientIp, username, key); ok || err != nil {
+			t.Fatal("unauthorized key was allowed", err)
+		}
+	}
+
+	t.Error("the attacker was never locked out")
+}
+
+func TestAuthHandlerRateLimit(t *testing.T) {
+
+	limiter := &MemoryRateLimiter{ChallengeRate: 0.5, ChallengeBurst: 1}
+	O := &Octokey{ChallengeSecret: []byte("hello world"), RateLimiter: limiter}
+	h := O.NewAuthHandler(NewMemoryKeyStore(), http.NotFoundHandler())
+	h.BaseUrl = "http://example.com"
+
+	for i, expected := range []int{401, 429} {
+		r := httptest.NewRequest("GET", "http://example.com/", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != expected {
+			t.Error(i, w.Code, "!=", expected)
+		}
+		if expected == 429 && w.Header().Get("Retry-After") != "2" {
+			t.Error("unexpected Retry-After", w.Header().Get("Retry-After"))
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package octokey
+
+import (
+	"errors"
+	"time"
+)
+
+// A Secret is an HMAC key used to sign challenges and sessions. The Id is
+// never sent to clients, it is used to refer to the secret when rotating.
+type Secret struct {
+	Id      string
+	Value   []byte
+	Retired bool
+	// superseded is when a newer secret was added
+	superseded time.Time
+}
+
+var (
+	ErrNoSecret        = errors.New("octokey/secret: no challenge secret")
+	ErrSecretNotFound  = errors.New("octokey/secret: no such secret")
+	ErrSecretDuplicate = errors.New("octokey/secret: duplicate secret id")
+	ErrSecretEmpty     = errors.New("octokey/secret: empty secret")
+	ErrSecretId        = errors.New("octokey/secret: empty secret id")
+	ErrSecretInUse     = errors.New("octokey/secret: secret may still have signed valid challenges or sessions")
+)
+
+// The id of the ChallengeSecret, for use with RetireSecret
+const CHALLENGE_SECRET_ID = ""
+
+// AddSecret adds a new secret, which will be used to sign all new challenges
+// and sessions. Challenges and sessions signed by any secret that has not been
+// retired are valid. To rotate secrets without breaking challenges that are in
+// flight or sessions that are still valid, add the new secret and then retire
+// the old one once both MaxAge and SessionMaxAge have passed; RetireSecret
+// refuses to retire it sooner. Once a secret has been added, ChallengeSecret is
+// no longer used to sign, but what it signed is valid until it is retired with
+// RetireSecret(CHALLENGE_SECRET_ID).
+func (O *Octokey) AddSecret(id string, value []byte) error {
+	O.mutex.Lock()
+	defer O.mutex.Unlock()
+
+	if id == CHALLENGE_SECRET_ID {
+		return ErrSecretId
+	}
+
+	if len(value) == 0 {
+		return ErrSecretEmpty
+	}
+
+	for _, s := range O.secrets {
+		if s.Id == id {
+			return ErrSecretDuplicate
+		}
+	}
+
+	currentTime := O.now()
+
+	if O.challengeSecretSuperseded.IsZero() {
+		O.challengeSecretSuperseded = currentTime
+	}
+
+	for i := range O.secrets {
+		if O.secrets[i].superseded.IsZero() {
+			O.secrets[i].superseded = currentTime
+		}
+	}
+
+	O.secrets = append(O.secrets, Secret{Id: id, Value: value})
+
+	return nil
+}
+
+// RetireSecret stops a secret from being used to sign or validate challenges
+// and sessions. It returns ErrSecretInUse unless a newer secret was added at
+// least MaxAge and SessionMaxAge ago, so that nothing valid is broken. The
+// ChallengeSecret can be retired with the id CHALLENGE_SECRET_ID.
+func (O *Octokey) RetireSecret(id string) error {
+	return O.retireSecret(id, false)
+}
+
+// RevokeSecret retires a secret straight away, for example because it has
+// leaked. Every challenge and session signed with it becomes invalid.
+func (O *Octokey) RevokeSecret(id string) error {
+	return O.retireSecret(id, true)
+}
+
+// retireSecret retires the secret, checking that it is no longer in use
+// unless it is being revoked.
+func (O *Octokey) retireSecret(id string, revoke bool) error {
+	O.mutex.Lock()
+	defer O.mutex.Unlock()
+
+	if id == CHALLENGE_SECRET_ID && len(O.ChallengeSecret) > 0 {
+		if !revoke && !O.secretExpired(O.challengeSecretSuperseded) {
+			return ErrSecretInUse
+		}
+		O.challengeSecretRetired = true
+		return nil
+	}
+
+	for i := range O.secrets {
+		if O.secrets[i].Id == id {
+			if !revoke && !O.secretExpired(O.secrets[i].superseded) {
+				return ErrSecretInUse
+			}
+			O.secrets[i].Retired = true
+			return nil
+		}
+	}
+
+	return ErrSecretNotFound
+}
+
+// secretExpired returns true if a secret that was superseded at the given
+// time can no longer have signed a valid challenge or session.
+func (O *Octokey) secretExpired(superseded time.Time) bool {
+
+	if superseded.IsZero() {
+		return false
+	}
+
+	lifetime := O.maxAge()
+	if O.sessionMaxAge() > lifetime {
+		lifetime = O.sessionMaxAge()
+	}
+
+	return !O.now().Before(superseded.Add(lifetime))
+}
+
+// Secrets returns a copy of the secrets added with AddSecret, including
+// those that have been retired, oldest first.
+func (O *Octokey) Secrets() []Secret {
+	O.mutex.RLock()
+	defer O.mutex.RUnlock()
+
+	secrets := make([]Secret, len(O.secrets))
+	for i, s := range O.secrets {
+		secrets[i] = s
+		secrets[i].Value = append([]byte{}, s.Value...)
+	}
+
+	return secrets
+}
+
+// currentSecret returns the secret that should be used to sign new challenges.
+// The ChallengeSecret is only used if no secrets have been added.
+func (O *Octokey) currentSecret() (Secret, error) {
+	O.mutex.RLock()
+	defer O.mutex.RUnlock()
+
+	for i := len(O.secrets) - 1; i >= 0; i-- {
+		if !O.secrets[i].Retired {
+			return O.secrets[i], nil
+		}
+	}
+
+	if len(O.secrets) == 0 && len(O.ChallengeSecret) > 0 && !O.challengeSecretRetired {
+		return Secret{Id: CHALLENGE_SECRET_ID, Value: O.ChallengeSecret}, nil
+	}
+
+	return Secret{}, ErrNoSecret
+}
+
+// activeSecrets returns all the secrets that have not been retired, oldest
+// first, starting with the ChallengeSecret.
+func (O *Octokey) activeSecrets() []Secret {
+	O.mutex.RLock()
+	defer O.mutex.RUnlock()
+
+	secrets := make([]Secret, 0, len(O.secrets)+1)
+
+	if len(O.ChallengeSecret) > 0 && !O.challengeSecretRetired {
+		secrets = append(secrets, Secret{Id: CHALLENGE_SECRET_ID, Value: O.ChallengeSecret})
+	}
+
+	for _, s := range O.secrets {
+		if !s.Retired {
+			secrets = append(secrets, s)
+		}
+	}
+
+	return secrets
+}
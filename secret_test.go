@@ -0,0 +1,162 @@
+package octokey
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSecretRotation(t *testing.T) {
+
+	O := &Octokey{}
+	clientIp := net.ParseIP("127.0.0.1")
+
+	if _, err := O.NewChallenge(clientIp); err != ErrNoSecret {
+		t.Fatal("expected ErrNoSecret, got", err)
+	}
+
+	if err := O.AddSecret("2012-q1", []byte("hello world")); err != nil {
+		t.Fatal(err)
+	}
+	if err := O.AddSecret("2012-q1", []byte("goodbye world")); err != ErrSecretDuplicate {
+		t.Error("expected ErrSecretDuplicate, got", err)
+	}
+
+	old, err := O.NewChallenge(clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := O.AddSecret("2012-q2", []byte("goodbye world")); err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := O.NewChallenge(clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for id, s := range map[string]string{"2012-q1": old, "2012-q2": current} {
+		c := Challenge{O: O}
+		c.ReadFrom(s, clientIp)
+		if len(c.Errors) > 0 {
+			t.Error(id, c.Errors)
+		}
+		if c.SecretId != id {
+			t.Error(c.SecretId, "!=", id)
+		}
+		if c.String() != s {
+			t.Error(id, c.String(), "!=", s)
+		}
+	}
+
+	if err := O.RetireSecret("2012-q1"); err != ErrSecretInUse {
+		t.Error("expected ErrSecretInUse, got", err)
+	}
+	if err := O.RevokeSecret("2012-q1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := O.RetireSecret("2011-q4"); err != ErrSecretNotFound {
+		t.Error("expected ErrSecretNotFound, got", err)
+	}
+
+	if err := O.ValidateChallenge(old, clientIp); err == nil {
+		t.Error("challenge signed by retired secret was valid")
+	}
+	if err := O.ValidateChallenge(current, clientIp); err != nil {
+		t.Error(err)
+	}
+
+	secrets := O.Secrets()
+	if len(secrets) != 2 || secrets[0].Id != "2012-q1" || !secrets[0].Retired || secrets[1].Retired {
+		t.Error("unexpected secrets", secrets)
+	}
+
+	secrets[1].Retired = true
+	secrets[1].Value[0] = 'x'
+	if O.Secrets()[1].Retired || O.Secrets()[1].Value[0] != 'g' {
+		t.Error("Secrets returned the live secrets")
+	}
+}
+
+func TestSecretMigration(t *testing.T) {
+
+	O := &Octokey{ChallengeSecret: []byte("hello world")}
+	clientIp := net.ParseIP("127.0.0.1")
+
+	old, err := O.NewChallenge(clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := O.AddSecret(CHALLENGE_SECRET_ID, []byte("goodbye world")); err != ErrSecretId {
+		t.Error("expected ErrSecretId, got", err)
+	}
+	if err := O.AddSecret("2012-q1", []byte("goodbye world")); err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := O.NewChallenge(clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := Challenge{O: O}
+	c.ReadFrom(current, clientIp)
+	if c.SecretId != "2012-q1" {
+		t.Error("new challenges should be signed with the added secret, got", c.SecretId)
+	}
+
+	if err := O.ValidateChallenge(old, clientIp); err != nil {
+		t.Error("challenge signed with ChallengeSecret should still be valid", err)
+	}
+
+	if err := O.RevokeSecret(CHALLENGE_SECRET_ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := O.ValidateChallenge(old, clientIp); err == nil {
+		t.Error("challenge signed with retired ChallengeSecret was valid")
+	}
+
+	if err := O.RetireSecret("2012-q1"); err != ErrSecretInUse {
+		t.Error("expected ErrSecretInUse for the current secret, got", err)
+	}
+	if err := O.RevokeSecret("2012-q1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := O.NewChallenge(clientIp); err != ErrNoSecret {
+		t.Error("expected ErrNoSecret, got", err)
+	}
+}
+
+func TestSecretRotationKeepsSessions(t *testing.T) {
+
+	clock := NewFakeClock(time.Date(2012, 7, 12, 22, 12, 58, 0, time.UTC))
+	O := &Octokey{Clock: clock}
+
+	if err := O.AddSecret("2012-q1", []byte("hello world")); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := O.NewSession("conrad", newTestSigner(t, "conrad").PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := O.AddSecret("2012-q2", []byte("goodbye world")); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance((MAX_AGE + 1) * time.Second)
+	if err := O.RetireSecret("2012-q1"); err != ErrSecretInUse {
+		t.Error("expected ErrSecretInUse while sessions may be valid, got", err)
+	}
+	if _, err := O.ReadSession(s); err != nil {
+		t.Error("session broken by rotation", err)
+	}
+
+	clock.Advance(SESSION_MAX_AGE * time.Second)
+	if err := O.RetireSecret("2012-q1"); err != nil {
+		t.Error(err)
+	}
+}
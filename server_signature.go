@@ -0,0 +1,112 @@
+package octokey
+
+import (
+	"bytes"
+	"errors"
+	"github.com/octokey/octokey-go/buffer"
+)
+
+// A ServerSigner signs challenges so that clients can check which server
+// issued them. Any Signer is also a ServerSigner.
+type ServerSigner interface {
+	PublicKey() Key
+	Sign(algorithm string, data []byte) ([]byte, error)
+}
+
+const (
+	// The first field of a server-signed challenge, which is also covered by
+	// the server's signature
+	SERVER_SIGNED_CHALLENGE = "octokey-server-signed"
+	// The algorithm used by servers to sign challenges with RSA keys
+	SERVER_SIGNING_ALGORITHM = SIGNING_ALGORITHM_RSA_SHA2_256
+)
+
+var (
+	ErrServerSignatureMissing = errors.New("octokey/server_signature: challenge not signed by server")
+	ErrServerSignatureInvalid = errors.New("octokey/server_signature: server signature invalid")
+)
+
+// signAsServer wraps the challenge with a signature by the ServerSigner.
+func (O *Octokey) signAsServer(challenge *buffer.Buffer) (string, error) {
+
+	b := new(buffer.Buffer)
+	b.AddString(SERVER_SIGNED_CHALLENGE)
+	b.AddBuffer(challenge)
+
+	algorithm := signingAlgorithmFor(O.ServerSigner.PublicKey(), SERVER_SIGNING_ALGORITHM)
+	sig, err := O.ServerSigner.Sign(algorithm, b.Raw())
+	if err != nil {
+		return "", err
+	}
+
+	s := new(buffer.Buffer)
+	s.AddString(algorithm)
+	s.AddVarBytes(sig)
+
+	b.AddBuffer(s)
+
+	if b.Error != nil {
+		return "", b.Error
+	}
+
+	return b.String(), nil
+}
+
+// unwrapChallenge returns the challenge inside a server-signed challenge,
+// checking the server's signature if serverKey is not nil. Challenges that
+// are not server-signed are returned as is, unless serverKey is not nil.
+func unwrapChallenge(s string, serverKey Key) (string, error) {
+
+	b := buffer.NewBuffer(s)
+
+	if !isServerSigned(b) {
+		if serverKey != nil {
+			return "", ErrServerSignatureMissing
+		}
+		return s, nil
+	}
+
+	b.ScanString()
+	challenge := b.ScanBuffer()
+	signature := b.ScanBuffer()
+	b.ScanEof()
+
+	if b.Error != nil {
+		return "", malformed(ErrChallengeMalformed, b.Error)
+	}
+
+	if serverKey != nil {
+		algorithm := signature.ScanString()
+		sig := signature.ScanVarBytes()
+		signature.ScanEof()
+
+		if signature.Error != nil || algorithm != signingAlgorithmFor(serverKey, SERVER_SIGNING_ALGORITHM) {
+			return "", ErrServerSignatureInvalid
+		}
+
+		signed := new(buffer.Buffer)
+		signed.AddString(SERVER_SIGNED_CHALLENGE)
+		signed.AddBuffer(challenge)
+
+		if serverKey.Verify(algorithm, signed.Raw(), sig) != nil {
+			return "", ErrServerSignatureInvalid
+		}
+	}
+
+	return challenge.String(), nil
+}
+
+// isServerSigned returns true if the buffer starts with SERVER_SIGNED_CHALLENGE.
+// Plain challenges start with their version, which is never 0, so can't be
+// confused with the length of the string.
+func isServerSigned(b *buffer.Buffer) bool {
+
+	if b.Error != nil {
+		return false
+	}
+
+	prefix := new(buffer.Buffer)
+	prefix.AddString(SERVER_SIGNED_CHALLENGE)
+
+	return bytes.HasPrefix(b.Raw(), prefix.Raw())
+}
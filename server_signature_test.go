@@ -0,0 +1,58 @@
+package octokey
+
+import (
+	"net"
+	"testing"
+)
+
+func TestServerSignedChallenge(t *testing.T) {
+
+	clientIp := net.ParseIP("127.0.0.1")
+	server := newTestSigner(t, "example.com")
+	impostor := newTestSigner(t, "example.com")
+
+	O := &Octokey{ChallengeSecret: []byte("hello world"), ServerSigner: server}
+	client := &Octokey{ServerKey: server.PublicKey()}
+	unpinned := &Octokey{}
+	signer := newTestSigner(t, "conrad")
+
+	c, err := O.NewChallenge(clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := client.SignChallenge(c, "https://example.com/login", signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := O.VerifyAuthRequest(s, "https://example.com/login", clientIp); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := unpinned.SignChallenge(c, "https://example.com/login", signer); err != nil {
+		t.Error("unpinned client could not sign server-signed challenge", err)
+	}
+
+	if err := O.ValidateChallenge(c, clientIp); err != nil {
+		t.Error(err)
+	}
+
+	plain, err := (&Octokey{ChallengeSecret: []byte("hello world")}).NewChallenge(clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.SignChallenge(plain, "https://example.com/login", signer); err != ErrServerSignatureMissing {
+		t.Error("expected ErrServerSignatureMissing, got", err)
+	}
+
+	forged, err := (&Octokey{ChallengeSecret: []byte("hello world"), ServerSigner: impostor}).NewChallenge(clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.SignChallenge(forged, "https://example.com/login", signer); err != ErrServerSignatureInvalid {
+		t.Error("expected ErrServerSignatureInvalid, got", err)
+	}
+}
@@ -0,0 +1,322 @@
+package octokey
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"github.com/octokey/octokey-go/buffer"
+	"sync"
+	"time"
+)
+
+const (
+	// Which version of sessions is supported
+	SESSION_VERSION = 1
+	// The default lifetime of a session (seconds)
+	SESSION_MAX_AGE = 12 * 60 * 60
+	// How many bytes of random data identify a session
+	SESSION_ID_SIZE = 16
+	// Included in the HMAC of sessions so that they can't be confused with
+	// challenges signed by the same secret
+	SESSION_CONTEXT = "octokey-session"
+)
+
+var (
+	ErrSessionInvalid         = errors.New("octokey/session: invalid session")
+	ErrSessionMalformed       = errors.New("octokey/session: malformed session")
+	ErrSessionVersionMismatch = errors.New("octokey/session: version mismatch")
+	ErrSessionExpired         = errors.New("octokey/session: session expired")
+	ErrSessionHmacMismatch    = errors.New("octokey/session: session HMAC mismatch")
+	ErrSessionRevoked         = errors.New("octokey/session: session revoked")
+	ErrSessionNoDenylist      = errors.New("octokey/session: no SessionDenylist")
+	ErrSessionNoKey           = errors.New("octokey/session: no public key")
+)
+
+// A SessionError is returned by ReadSession. It contains every reason that
+// the session was invalid, and can be inspected with errors.Is. It is also
+// always ErrSessionInvalid.
+type SessionError struct {
+	Errors []error
+}
+
+func (e *SessionError) Error() string {
+	return joinErrors(ErrSessionInvalid, e.Errors)
+}
+
+func (e *SessionError) Is(target error) bool {
+	return target == ErrSessionInvalid
+}
+
+func (e *SessionError) Unwrap() []error {
+	return e.Errors
+}
+
+// A Session is a signed token given to a user after they have logged in with
+// Octokey, so that they don't have to sign an auth request for every request.
+// It is signed with the same secrets as challenges, so RetireSecret waits
+// for sessions to expire as well as challenges.
+type Session struct {
+	O           *Octokey
+	Version     uint8
+	Id          []byte
+	Username    string
+	Fingerprint string
+	AuthTime    time.Time
+	Expires     time.Time
+	Digest      []byte
+	Errors      []error
+	// SecretId is the id of the secret that signed the session
+	SecretId string
+	secret   []byte
+}
+
+// A SessionDenylist remembers which sessions have been revoked until they
+// expire. Sessions are identified by their Id.
+type SessionDenylist interface {
+	// Revoke stops the session from being valid.
+	Revoke(id []byte, expires time.Time) error
+	// IsRevoked returns true if the session has been revoked.
+	IsRevoked(id []byte) (bool, error)
+}
+
+// NewSession creates a session for a user who has just logged in with the
+// given public key, for example as returned by VerifyAuthRequest.
+func (O *Octokey) NewSession(username string, key Key) (string, error) {
+
+	if key == nil {
+		return "", ErrSessionNoKey
+	}
+
+	secret, err := O.currentSecret()
+	if err != nil {
+		return "", err
+	}
+
+	id := make([]byte, SESSION_ID_SIZE)
+	_, err = rand.Read(id)
+	if err != nil {
+		return "", err
+	}
+
+	session := Session{O: O}
+	session.Version = SESSION_VERSION
+	session.Id = id
+	session.Username = username
+	session.Fingerprint = key.Fingerprint()
+	session.AuthTime = O.now()
+	session.Expires = session.AuthTime.Add(O.sessionMaxAge())
+	session.SecretId = secret.Id
+	session.secret = secret.Value
+	session.Digest = session.expectedDigest()
+
+	b := session.signedBuffer()
+	if b.Error != nil {
+		return "", b.Error
+	}
+
+	return b.String(), nil
+}
+
+// ReadSession checks that a session is valid, and returns it.
+func (O *Octokey) ReadSession(s string) (*Session, error) {
+
+	session := &Session{O: O}
+	session.ReadFrom(s)
+
+	if len(session.Errors) == 0 && O.SessionDenylist != nil {
+		revoked, err := O.SessionDenylist.IsRevoked(session.Id)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			session.Errors = append(session.Errors, ErrSessionRevoked)
+		}
+	}
+
+	if len(session.Errors) > 0 {
+		return nil, &SessionError{Errors: session.Errors}
+	}
+
+	return session, nil
+}
+
+// RevokeSession stops a session from being valid, for example when the user
+// logs out. It needs a SessionDenylist.
+func (O *Octokey) RevokeSession(s string) error {
+
+	if O.SessionDenylist == nil {
+		return ErrSessionNoDenylist
+	}
+
+	session, err := O.ReadSession(s)
+	if errors.Is(err, ErrSessionRevoked) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return O.SessionDenylist.Revoke(session.Id, session.Expires)
+}
+
+// ReadFrom reads the session from its Base64 format, and validates it.
+// Any problems are added to s.Errors.
+func (s *Session) ReadFrom(str string) {
+	b := buffer.NewBuffer(str)
+	currentTime := s.O.now()
+
+	s.Version = b.ScanUint8()
+	s.Id = b.ScanVarBytes()
+	s.Username = b.ScanString()
+	s.Fingerprint = b.ScanString()
+	s.AuthTime = b.ScanTimestamp()
+	s.Expires = b.ScanTimestamp()
+	s.Digest = b.ScanVarBytes()
+	b.ScanEof()
+
+	if b.Error != nil {
+		s.Errors = append(s.Errors, malformed(ErrSessionMalformed, b.Error))
+		return
+	}
+
+	if s.Version != SESSION_VERSION {
+		s.Errors = append(s.Errors, ErrSessionVersionMismatch)
+		return
+	}
+
+	if !currentTime.Before(s.Expires) {
+		s.Errors = append(s.Errors, ErrSessionExpired)
+	}
+
+	if !s.verifyDigest() {
+		s.Errors = append(s.Errors, ErrSessionHmacMismatch)
+	}
+}
+
+// String returns the session in Base64 format
+func (s *Session) String() string {
+	return s.signedBuffer().String()
+}
+
+// verifyDigest checks whether the session was signed by any of the active
+// secrets, and if so remembers which one.
+func (s *Session) verifyDigest() bool {
+	for _, secret := range s.O.activeSecrets() {
+		if hmac.Equal(s.Digest, s.digest(secret.Value)) {
+			s.SecretId = secret.Id
+			s.secret = secret.Value
+			return true
+		}
+	}
+	return false
+}
+
+// expectedDigest calculates the HMAC of the unsignedBuffer with the secret
+// that signed the session, or the current secret if that is not known.
+func (s *Session) expectedDigest() []byte {
+	secret := s.secret
+	if secret == nil {
+		current, _ := s.O.currentSecret()
+		secret = current.Value
+	}
+	return s.digest(secret)
+}
+
+// digest calculates the HMAC-SHA256 of the SESSION_CONTEXT and the
+// unsignedBuffer with the given secret
+func (s *Session) digest(secret []byte) []byte {
+	toSign := new(buffer.Buffer)
+	toSign.AddString(SESSION_CONTEXT)
+	toSign.Write(s.unsignedBuffer().Raw())
+	h := hmac.New(sha256.New, secret)
+	h.Write(toSign.Raw())
+	return h.Sum(nil)
+}
+
+// unsignedBuffer is an octokey buffer containing everything except the signature
+func (s *Session) unsignedBuffer() *buffer.Buffer {
+	b := new(buffer.Buffer)
+	b.AddUint8(s.Version)
+	b.AddVarBytes(s.Id)
+	b.AddString(s.Username)
+	b.AddString(s.Fingerprint)
+	b.AddTimestamp(s.AuthTime)
+	b.AddTimestamp(s.Expires)
+	return b
+}
+
+// signedBuffer is an octokey buffer containing everything including the signature
+func (s *Session) signedBuffer() *buffer.Buffer {
+	b := s.unsignedBuffer()
+	b.AddVarBytes(s.expectedDigest())
+	return b
+}
+
+// sessionMaxAge returns the lifetime of new sessions.
+func (O *Octokey) sessionMaxAge() time.Duration {
+	if O.SessionMaxAge == 0 {
+		return SESSION_MAX_AGE * time.Second
+	}
+	return O.SessionMaxAge
+}
+
+// A MemorySessionDenylist is a SessionDenylist that keeps everything in
+// memory. If you run more than one server you will need to share a
+// SessionDenylist between them instead. The zero value is ready to use.
+type MemorySessionDenylist struct {
+	// Clock is used to forget about expired sessions. Defaults to the real
+	// time.
+	Clock Clock
+
+	revoked   map[string]time.Time
+	nextSweep time.Time
+	mutex     sync.Mutex
+}
+
+// How often a MemorySessionDenylist forgets about expired sessions (seconds)
+const SESSION_DENYLIST_SWEEP_INTERVAL = 60
+
+// NewMemorySessionDenylist creates an empty MemorySessionDenylist.
+func NewMemorySessionDenylist() *MemorySessionDenylist {
+	return &MemorySessionDenylist{}
+}
+
+func (d *MemorySessionDenylist) Revoke(id []byte, expires time.Time) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.revoked == nil {
+		d.revoked = make(map[string]time.Time)
+	}
+
+	d.sweep()
+	d.revoked[string(id)] = expires
+
+	return nil
+}
+
+func (d *MemorySessionDenylist) IsRevoked(id []byte) (bool, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.sweep()
+	_, ok := d.revoked[string(id)]
+
+	return ok, nil
+}
+
+// sweep forgets about sessions that have expired, as they are invalid anyway.
+func (d *MemorySessionDenylist) sweep() {
+
+	currentTime := clockOrDefault(d.Clock).Now()
+
+	if currentTime.After(d.nextSweep) {
+		for k, e := range d.revoked {
+			if currentTime.After(e) {
+				delete(d.revoked, k)
+			}
+		}
+		d.nextSweep = currentTime.Add(SESSION_DENYLIST_SWEEP_INTERVAL * time.Second)
+	}
+}
@@ -0,0 +1,113 @@
+package octokey
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSession(t *testing.T) {
+
+	clock := NewFakeClock(time.Date(2012, 7, 12, 22, 12, 58, 0, time.UTC))
+	O := &Octokey{ChallengeSecret: []byte("hello world"), Clock: clock, SessionMaxAge: time.Hour}
+	key := newTestSigner(t, "conrad").PublicKey()
+
+	s, err := O.NewSession("conrad", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(30 * time.Minute)
+
+	session, err := O.ReadSession(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if session.Username != "conrad" || session.Fingerprint != key.Fingerprint() ||
+		!session.AuthTime.Equal(clock.Now().Add(-30*time.Minute)) || !session.Expires.Equal(clock.Now().Add(30*time.Minute)) {
+		t.Error("unexpected session", session)
+	}
+
+	if session.String() != s {
+		t.Error(session.String(), "!=", s)
+	}
+
+	other := &Octokey{ChallengeSecret: []byte("goodbye world"), Clock: clock}
+	if _, err := other.ReadSession(s); !errors.Is(err, ErrSessionHmacMismatch) || !errors.Is(err, ErrSessionInvalid) {
+		t.Error("expected ErrSessionHmacMismatch, got", err)
+	}
+
+	if _, err := O.NewSession("conrad", nil); err != ErrSessionNoKey {
+		t.Error("expected ErrSessionNoKey, got", err)
+	}
+
+	if _, err := O.ReadSession(s[:len(s)-4]); !errors.Is(err, ErrSessionMalformed) {
+		t.Error("expected ErrSessionMalformed, got", err)
+	}
+
+	clock.Advance(30 * time.Minute)
+	if _, err := O.ReadSession(s); !errors.Is(err, ErrSessionExpired) {
+		t.Error("expected ErrSessionExpired, got", err)
+	}
+}
+
+func TestSessionIsNotAChallenge(t *testing.T) {
+
+	O := &Octokey{ChallengeSecret: []byte("hello world")}
+	clientIp := net.ParseIP("127.0.0.1")
+
+	c, err := O.NewChallenge(clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := O.ReadSession(c); err == nil {
+		t.Error("challenge was a valid session")
+	}
+
+	s, err := O.NewSession("conrad", newTestSigner(t, "conrad").PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := O.ValidateChallenge(s, clientIp); err == nil {
+		t.Error("session was a valid challenge")
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+
+	clock := NewFakeClock(time.Date(2012, 7, 12, 22, 12, 58, 0, time.UTC))
+	denylist := &MemorySessionDenylist{Clock: clock}
+	O := &Octokey{ChallengeSecret: []byte("hello world"), Clock: clock}
+	key := newTestSigner(t, "conrad").PublicKey()
+
+	s1, _ := O.NewSession("conrad", key)
+	s2, _ := O.NewSession("conrad", key)
+
+	if err := O.RevokeSession(s1); err != ErrSessionNoDenylist {
+		t.Error("expected ErrSessionNoDenylist, got", err)
+	}
+
+	O.SessionDenylist = denylist
+
+	if err := O.RevokeSession(s1); err != nil {
+		t.Fatal(err)
+	}
+	if err := O.RevokeSession(s1); err != nil {
+		t.Error("revoking twice should succeed", err)
+	}
+
+	if _, err := O.ReadSession(s1); !errors.Is(err, ErrSessionRevoked) {
+		t.Error("expected ErrSessionRevoked, got", err)
+	}
+	if _, err := O.ReadSession(s2); err != nil {
+		t.Error("other sessions should be valid", err)
+	}
+
+	clock.Advance(SESSION_MAX_AGE*time.Second + SESSION_DENYLIST_SWEEP_INTERVAL*time.Second)
+	denylist.IsRevoked(nil)
+	if len(denylist.revoked) != 0 {
+		t.Error("expired session was not forgotten")
+	}
+}
@@ -0,0 +1,103 @@
+package octokey
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"github.com/octokey/octokey-go/buffer"
+	"math/big"
+)
+
+// NewKey returns the Key for an *rsa.PublicKey, an ed25519.PublicKey or an
+// *ecdsa.PublicKey on the P-256 curve.
+func NewKey(pub crypto.PublicKey) (Key, error) {
+
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return (*PublicKey)(k), nil
+	case ed25519.PublicKey:
+		return Ed25519Key(k), nil
+	case *ecdsa.PublicKey:
+		if k.Curve == elliptic.P256() {
+			return (*EcdsaKey)(k), nil
+		}
+	}
+
+	return nil, ErrKeyType
+}
+
+// A keySigner is a Signer that uses a crypto.Signer.
+type keySigner struct {
+	username string
+	signer   crypto.Signer
+	key      Key
+}
+
+// NewSigner returns a Signer that signs auth requests for the user with the
+// private key, which can be an *rsa.PrivateKey, an ed25519.PrivateKey, an
+// *ecdsa.PrivateKey on the P-256 curve, or any other crypto.Signer with one
+// of those types of public key.
+func NewSigner(username string, signer crypto.Signer) (Signer, error) {
+
+	key, err := NewKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return &keySigner{username: username, signer: signer, key: key}, nil
+}
+
+func (s *keySigner) PublicKey() Key {
+	return s.key
+}
+
+func (s *keySigner) Username() string {
+	return s.username
+}
+
+// Sign signs the data with the signing algorithm. ECDSA signatures are
+// converted from ASN.1 to the two mpints used by ssh.
+func (s *keySigner) Sign(algorithm string, data []byte) ([]byte, error) {
+
+	a, ok := signingAlgorithms[algorithm]
+	if !ok || a.keyType != s.key.Type() {
+		return nil, ErrKeySignatureAlgorithm
+	}
+
+	digest := data
+	if a.hash != 0 {
+		h := a.hash.New()
+		h.Write(data)
+		digest = h.Sum(nil)
+	}
+
+	sig, err := s.signer.Sign(rand.Reader, digest, a.hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.keyType != KEY_TYPE_ECDSA_NISTP256 {
+		return sig, nil
+	}
+
+	var rs struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(sig, &rs); err != nil {
+		return nil, err
+	}
+
+	b := new(buffer.Buffer)
+	b.AddMPInt(rs.R)
+	b.AddMPInt(rs.S)
+
+	if b.Error != nil {
+		return nil, b.Error
+	}
+
+	return b.Raw(), nil
+}
@@ -0,0 +1,90 @@
+package octokey
+
+import (
+	"crypto"
+	"errors"
+)
+
+// The algorithms that auth requests can be signed with. RSA keys can sign
+// with ssh-rsa or, as described in RFC 8332, rsa-sha2-256 and rsa-sha2-512;
+// SigningAlgorithm chooses which. The default for RSA keys,
+// SIGNING_ALGORITHM, is ssh-rsa for compatibility with older clients and
+// servers. Other keys sign with the algorithm of the same name.
+const (
+	SIGNING_ALGORITHM_SSH_RSA             = PUBLIC_KEY_TYPE
+	SIGNING_ALGORITHM_RSA_SHA2_256        = "rsa-sha2-256"
+	SIGNING_ALGORITHM_RSA_SHA2_512        = "rsa-sha2-512"
+	SIGNING_ALGORITHM_SSH_ED25519         = KEY_TYPE_ED25519
+	SIGNING_ALGORITHM_ECDSA_SHA2_NISTP256 = KEY_TYPE_ECDSA_NISTP256
+)
+
+var (
+	ErrPolicySigningAlgorithm = errors.New("octokey/policy: unsupported SigningAlgorithm")
+)
+
+// A signingAlgorithm is the type of key that can sign with the algorithm,
+// and the hash that the data is signed with. Ed25519 hashes the data itself.
+type signingAlgorithm struct {
+	keyType string
+	hash    crypto.Hash
+}
+
+var signingAlgorithms = map[string]signingAlgorithm{
+	SIGNING_ALGORITHM_SSH_RSA:             {PUBLIC_KEY_TYPE, crypto.SHA1},
+	SIGNING_ALGORITHM_RSA_SHA2_256:        {PUBLIC_KEY_TYPE, crypto.SHA256},
+	SIGNING_ALGORITHM_RSA_SHA2_512:        {PUBLIC_KEY_TYPE, crypto.SHA512},
+	SIGNING_ALGORITHM_SSH_ED25519:         {KEY_TYPE_ED25519, 0},
+	SIGNING_ALGORITHM_ECDSA_SHA2_NISTP256: {KEY_TYPE_ECDSA_NISTP256, crypto.SHA256},
+}
+
+// checkSigningAlgorithms returns an error if the signing algorithm policy is
+// invalid.
+func (O *Octokey) checkSigningAlgorithms() error {
+
+	if signingAlgorithms[O.signingAlgorithm()].keyType != PUBLIC_KEY_TYPE {
+		return ErrPolicySigningAlgorithm
+	}
+
+	for _, algorithm := range O.AcceptedSigningAlgorithms {
+		if _, ok := signingAlgorithms[algorithm]; !ok {
+			return ErrPolicySigningAlgorithm
+		}
+	}
+
+	return nil
+}
+
+// signingAlgorithm returns the algorithm used to sign new auth requests with
+// RSA keys.
+func (O *Octokey) signingAlgorithm() string {
+	if O.SigningAlgorithm == "" {
+		return SIGNING_ALGORITHM
+	}
+	return O.SigningAlgorithm
+}
+
+// signingAlgorithmFor returns the algorithm used to sign with the key, which
+// is rsaAlgorithm for RSA keys.
+func signingAlgorithmFor(key Key, rsaAlgorithm string) string {
+	if key.Type() == PUBLIC_KEY_TYPE {
+		return rsaAlgorithm
+	}
+	return key.Type()
+}
+
+// acceptsSigningAlgorithm returns true if auth requests signed with the given
+// algorithm are valid.
+func (O *Octokey) acceptsSigningAlgorithm(algorithm string) bool {
+	if _, ok := signingAlgorithms[algorithm]; !ok {
+		return false
+	}
+	if len(O.AcceptedSigningAlgorithms) == 0 {
+		return true
+	}
+	for _, a := range O.AcceptedSigningAlgorithms {
+		if a == algorithm {
+			return true
+		}
+	}
+	return false
+}
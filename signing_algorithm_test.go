@@ -0,0 +1,71 @@
+package octokey
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestSigningAlgorithms(t *testing.T) {
+
+	clientIp := net.ParseIP("127.0.0.1")
+	signer := newTestSigner(t, "conrad")
+	server := &Octokey{ChallengeSecret: []byte("hello world"), AcceptedSigningAlgorithms: []string{SIGNING_ALGORITHM_RSA_SHA2_256, SIGNING_ALGORITHM_RSA_SHA2_512}}
+
+	for _, test := range []struct {
+		algorithm string
+		valid     bool
+	}{
+		{SIGNING_ALGORITHM_SSH_RSA, false},
+		{SIGNING_ALGORITHM_RSA_SHA2_256, true},
+		{SIGNING_ALGORITHM_RSA_SHA2_512, true},
+	} {
+		client := &Octokey{SigningAlgorithm: test.algorithm}
+
+		c, err := server.NewChallenge(clientIp)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s, err := client.SignChallenge(c, "https://example.com/login", signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		a, err := server.ReadAuthRequest(s, "https://example.com/login", clientIp, nil)
+		if (err == nil) != test.valid {
+			t.Error(test.algorithm, err)
+		}
+		if err != nil && !errors.Is(err, ErrAuthRequestSigningAlgorithmMismatch) {
+			t.Error(test.algorithm, "expected ErrAuthRequestSigningAlgorithmMismatch, got", err)
+		}
+		if a != nil && a.SigningAlgorithm != test.algorithm {
+			t.Error(a.SigningAlgorithm, "!=", test.algorithm)
+		}
+	}
+
+	c, err := server.NewChallenge(clientIp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := (&Octokey{SigningAlgorithm: "ssh-dss"}).SignChallenge(c, "https://example.com/login", signer); err != ErrPolicySigningAlgorithm {
+		t.Error("expected ErrPolicySigningAlgorithm, got", err)
+	}
+
+	// The signature must use the algorithm named in the auth request.
+	s, err := (&Octokey{SigningAlgorithm: SIGNING_ALGORITHM_RSA_SHA2_256}).SignChallenge(c, "https://example.com/login", signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := AuthRequest{O: server}
+	a.ReadFrom(s, "https://example.com/login", clientIp)
+	a.SigningAlgorithm = SIGNING_ALGORITHM_RSA_SHA2_512
+	if err := a.verifySignature(); err != ErrAuthRequestSignatureAlgorithmMismatch {
+		t.Error("expected ErrAuthRequestSignatureAlgorithmMismatch, got", err)
+	}
+
+	if err := (&Octokey{AcceptedSigningAlgorithms: []string{"ssh-dss"}}).CheckPolicy(); err != ErrPolicySigningAlgorithm {
+		t.Error("expected ErrPolicySigningAlgorithm, got", err)
+	}
+}
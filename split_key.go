@@ -0,0 +1,76 @@
+package octokey
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"errors"
+	"io"
+)
+
+// A SplitKey is an mRSA key split between a local PartialKey and the escrow
+// servers that hold the other parts. It is a crypto.Signer that makes
+// PKCS#1 v1.5 signatures, so it can be used anywhere an *rsa.PrivateKey can
+// sign, for example by x509.CreateCertificateRequest.
+type SplitKey struct {
+	Local  *PartialKey
+	Remote []*PartialSigner
+}
+
+var (
+	ErrSplitKeyOptions = errors.New("octokey/split_key: only PKCS#1 v1.5 with an available hash is supported")
+	ErrSplitKeyDigest  = errors.New("octokey/split_key: digest has wrong length")
+)
+
+// The hashes that a SplitKey can sign digests of. These are the hashes that
+// PKCS#1 v1.5 has a DigestInfo prefix for, which is the same set that
+// crypto/rsa.SignPKCS1v15 accepts.
+var splitKeyHashes = map[crypto.Hash]bool{
+	crypto.MD5:       true,
+	crypto.SHA1:      true,
+	crypto.SHA224:    true,
+	crypto.SHA256:    true,
+	crypto.SHA384:    true,
+	crypto.SHA512:    true,
+	crypto.MD5SHA1:   true,
+	crypto.RIPEMD160: true,
+}
+
+// NewSplitKey returns a SplitKey that combines the local partial key with the
+// partial signatures from the escrow servers at each of the urls.
+func NewSplitKey(local *PartialKey, urls ...string) (*SplitKey, error) {
+
+	remote, err := partialSigners(local, urls)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SplitKey{Local: local, Remote: remote}, nil
+}
+
+// Public returns the *rsa.PublicKey of the split key.
+func (k *SplitKey) Public() crypto.PublicKey {
+	return (*rsa.PublicKey)(&k.Local.PublicKey)
+}
+
+// Sign makes a PKCS#1 v1.5 signature of the digest, which must have been
+// hashed with opts.HashFunc(). The hash must be linked into the binary and
+// have a PKCS#1 v1.5 DigestInfo prefix, and RSA-PSS is not supported; other
+// options return ErrSplitKeyOptions. The rand argument is ignored, as PKCS#1
+// v1.5 signatures are deterministic.
+func (k *SplitKey) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+
+	hash := opts.HashFunc()
+	if _, ok := opts.(*rsa.PSSOptions); ok || !hash.Available() || !splitKeyHashes[hash] {
+		return nil, ErrSplitKeyOptions
+	}
+
+	if len(digest) != hash.Size() {
+		return nil, ErrSplitKeyDigest
+	}
+
+	if len(k.Remote) == 0 {
+		return nil, ErrPartialSignerNoUrls
+	}
+
+	return signPKCS1v15(k.Local, k.Remote, hash, digest)
+}
@@ -0,0 +1,86 @@
+package octokey
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+)
+
+func TestSplitKey(t *testing.T) {
+
+	k1, k2, err := GeneratePartialKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	escrow := newTestEscrow(t, k2)
+
+	if _, err := NewSplitKey(k1); err != ErrPartialSignerNoUrls {
+		t.Error("expected ErrPartialSignerNoUrls, got", err)
+	}
+
+	key, err := NewSplitKey(k1, escrow.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var _ crypto.Signer = key
+	public := key.Public().(*rsa.PublicKey)
+
+	sha1Digest := sha1.Sum([]byte("hello world"))
+	sha256Digest := sha256.Sum256([]byte("hello world"))
+	sha384Digest := sha512.Sum384([]byte("hello world"))
+	sha224Digest := sha256.Sum224([]byte("hello world"))
+	sha512Digest := sha512.Sum512([]byte("hello world"))
+
+	tests := []struct {
+		digest []byte
+		opts   crypto.SignerOpts
+		err    error
+	}{
+		{sha1Digest[:], crypto.SHA1, nil},
+		{sha256Digest[:], crypto.SHA256, nil},
+		{sha512Digest[:], crypto.SHA512, nil},
+		{sha384Digest[:], crypto.SHA384, nil},
+		{sha224Digest[:], crypto.SHA224, nil},
+		{sha512Digest[:], crypto.SHA256, ErrSplitKeyDigest},
+		{sha256Digest[:], crypto.Hash(0), ErrSplitKeyOptions},
+		{sha256Digest[:], crypto.Hash(99), ErrSplitKeyOptions},
+		{sha1Digest[:], crypto.RIPEMD160, ErrSplitKeyOptions},
+		{sha256Digest[:], &rsa.PSSOptions{Hash: crypto.SHA256}, ErrSplitKeyOptions},
+	}
+
+	for _, test := range tests {
+		sig, err := key.Sign(rand.Reader, test.digest, test.opts)
+		if err != test.err {
+			t.Error("expected", test.err, "got", err)
+			continue
+		}
+		if err == nil {
+			if err := rsa.VerifyPKCS1v15(public, test.opts.HashFunc(), test.digest, sig); err != nil {
+				t.Error(test.opts.HashFunc(), err)
+			}
+		}
+	}
+
+	template := &x509.CertificateRequest{Subject: pkix.Name{CommonName: "conrad"}}
+	der, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := csr.CheckSignature(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,152 @@
+package octokey
+
+import (
+	"bytes"
+	"errors"
+	"github.com/octokey/octokey-go/buffer"
+	"net"
+	"time"
+)
+
+// Included in the payload of step-up challenges so that they can't be
+// confused with other challenges that have payloads
+const STEP_UP_CONTEXT = "octokey-step-up"
+
+var (
+	ErrStepUpOperationMismatch = errors.New("octokey/step_up: operation mismatch")
+	ErrStepUpUserMismatch      = errors.New("octokey/step_up: user mismatch")
+	ErrStepUpNotAStepUp        = errors.New("octokey/step_up: not a step-up challenge")
+	ErrStepUpNoChallengeStore  = errors.New("octokey/step_up: step-up challenges need a ChallengeStore")
+	ErrStepUpNoSession         = errors.New("octokey/step_up: no session")
+	ErrStepUpChallenge         = errors.New("octokey/step_up: step-up challenges can only be used with VerifyStepUp")
+)
+
+// StepUpRequired returns true if the user logged in longer ago than maxAge,
+// and so should sign a new step-up challenge before doing something
+// sensitive.
+func (O *Octokey) StepUpRequired(session *Session, maxAge time.Duration) bool {
+	return O.now().Sub(session.AuthTime) > maxAge
+}
+
+// NewStepUpChallenge creates a challenge that approves one operation, for
+// example "delete repo octokey/octokey-go". The client can read the operation
+// with StepUpOperation, so it should be something you'd be happy to show the
+// user. Step-up challenges need version 5 challenges, and a ChallengeStore so
+// that each approval can only be used once; without one it returns
+// ErrStepUpNoChallengeStore.
+func (O *Octokey) NewStepUpChallenge(clientIp net.IP, operation string) (string, error) {
+
+	if O.ChallengeStore == nil {
+		return "", ErrStepUpNoChallengeStore
+	}
+
+	payload, err := stepUpPayload(operation)
+	if err != nil {
+		return "", err
+	}
+
+	return O.NewChallengeWithPayload(clientIp, payload)
+}
+
+// VerifyStepUp verifies an auth request that signed a challenge created by
+// NewStepUpChallenge. It checks that the challenge was for the given operation,
+// and that the auth request is from the same user as the session. Step-up
+// challenges are rejected everywhere else, so an approval can't be used to log
+// in.
+//
+// It returns a new session for the user whose AuthTime is now, and the public
+// key that was used. Check that the key is still allowed to log in, for
+// example with Authorize, before replacing the old session with the new one.
+// Like NewStepUpChallenge it returns ErrStepUpNoChallengeStore if there is no
+// ChallengeStore, as approvals could otherwise be replayed.
+func (O *Octokey) VerifyStepUp(s string, requestUrl string, clientIp net.IP, session *Session, operation string) (string, Key, error) {
+
+	if O.ChallengeStore == nil {
+		return "", nil, ErrStepUpNoChallengeStore
+	}
+
+	if session == nil {
+		return "", nil, ErrStepUpNoSession
+	}
+
+	payload, err := stepUpPayload(operation)
+	if err != nil {
+		return "", nil, err
+	}
+
+	a, err := O.readAuthRequest(&AuthRequest{O: O, stepUp: true}, s, requestUrl, clientIp)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if !bytes.Equal(a.Challenge.Payload, payload) {
+		return "", nil, ErrStepUpOperationMismatch
+	}
+
+	if a.Username != session.Username {
+		return "", nil, ErrStepUpUserMismatch
+	}
+
+	refreshed, err := O.NewSession(a.Username, a.PublicKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return refreshed, a.PublicKey, nil
+}
+
+// StepUpOperation returns the operation that a step-up challenge approves, so
+// that the client can show it to the user before signing. It does not check
+// that the challenge is valid, only the server can do that.
+func StepUpOperation(challenge string) (string, error) {
+
+	challenge, err := unwrapChallenge(challenge, nil)
+	if err != nil {
+		return "", err
+	}
+
+	c := Challenge{}
+	b := buffer.NewBuffer(challenge)
+	c.scanBuffer(b)
+
+	if b.Error != nil {
+		return "", malformed(ErrChallengeMalformed, b.Error)
+	}
+
+	operation, ok := stepUpOperation(c.Payload)
+	if !ok {
+		return "", ErrStepUpNotAStepUp
+	}
+
+	return operation, nil
+}
+
+// stepUpOperation reads the operation from the payload of a challenge. It
+// returns false if the challenge is not a step-up challenge.
+func stepUpOperation(payload []byte) (string, bool) {
+
+	p := &buffer.Buffer{Buffer: *bytes.NewBuffer(payload)}
+	context := p.ScanString()
+	operation := p.ScanString()
+	p.ScanEof()
+
+	if p.Error != nil || context != STEP_UP_CONTEXT {
+		return "", false
+	}
+
+	return operation, true
+}
+
+// stepUpPayload is the payload of a step-up challenge for the operation.
+func stepUpPayload(operation string) ([]byte, error) {
+
+	b := new(buffer.Buffer)
+	b.AddString(STEP_UP_CONTEXT)
+	b.AddString(operation)
+
+	if b.Error != nil {
+		return nil, b.Error
+	}
+
+	return b.Raw(), nil
+}
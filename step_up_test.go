@@ -0,0 +1,117 @@
+package octokey
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStepUp(t *testing.T) {
+
+	clock := NewFakeClock(time.Date(2012, 7, 12, 22, 12, 58, 0, time.UTC))
+	store := NewMemoryChallengeStore()
+	store.Clock = clock
+	O := &Octokey{
+		ChallengeSecret:  []byte("hello world"),
+		ChallengeVersion: 5,
+		ChallengeStore:   store,
+		Clock:            clock,
+	}
+	signer := newTestSigner(t, "conrad")
+	clientIp := net.ParseIP("127.0.0.1")
+	requestUrl := "https://example.com/step-up"
+
+	s, err := O.NewSession("conrad", signer.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := O.ReadSession(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if O.StepUpRequired(session, 10*time.Minute) {
+		t.Error("step-up required straight after login")
+	}
+	clock.Advance(11 * time.Minute)
+	if !O.StepUpRequired(session, 10*time.Minute) {
+		t.Error("step-up not required 11 minutes after login")
+	}
+
+	c, err := O.NewStepUpChallenge(clientIp, "delete repo octokey/octokey-go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	operation, err := StepUpOperation(c)
+	if err != nil || operation != "delete repo octokey/octokey-go" {
+		t.Error("unexpected operation", operation, err)
+	}
+
+	approval, err := O.SignChallenge(c, requestUrl, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := O.VerifyStepUp(approval, requestUrl, clientIp, session, "change billing plan"); err != ErrStepUpOperationMismatch {
+		t.Error("expected ErrStepUpOperationMismatch, got", err)
+	}
+
+	// The challenge was used up by the mismatched operation, so get a new one
+	c, _ = O.NewStepUpChallenge(clientIp, "delete repo octokey/octokey-go")
+	approval, _ = O.SignChallenge(c, requestUrl, signer)
+
+	if _, _, err := O.VerifyAuthRequest(approval, requestUrl, clientIp); !errors.Is(err, ErrStepUpChallenge) {
+		t.Error("expected ErrStepUpChallenge, got", err)
+	}
+
+	if _, _, err := O.VerifyStepUp(approval, requestUrl, clientIp, nil, "delete repo octokey/octokey-go"); err != ErrStepUpNoSession {
+		t.Error("expected ErrStepUpNoSession, got", err)
+	}
+
+	refreshed, key, err := O.VerifyStepUp(approval, requestUrl, clientIp, session, "delete repo octokey/octokey-go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.Equal(signer.PublicKey()) {
+		t.Error("unexpected key")
+	}
+	session, err = O.ReadSession(refreshed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Username != "conrad" || O.StepUpRequired(session, 10*time.Minute) {
+		t.Error("step-up still required after stepping up", session.Username)
+	}
+
+	if _, _, err := O.VerifyStepUp(approval, requestUrl, clientIp, session, "delete repo octokey/octokey-go"); !errors.Is(err, ErrChallengeUsed) {
+		t.Error("expected ErrChallengeUsed, got", err)
+	}
+
+	c, _ = O.NewStepUpChallenge(clientIp, "delete repo octokey/octokey-go")
+	approval, _ = O.SignChallenge(c, requestUrl, newTestSigner(t, "martin"))
+	if _, _, err := O.VerifyStepUp(approval, requestUrl, clientIp, session, "delete repo octokey/octokey-go"); err != ErrStepUpUserMismatch {
+		t.Error("expected ErrStepUpUserMismatch, got", err)
+	}
+
+	c, _ = O.NewChallengeWithPayload(clientIp, []byte("delete repo octokey/octokey-go"))
+	if _, err := StepUpOperation(c); err != ErrStepUpNotAStepUp {
+		t.Error("expected ErrStepUpNotAStepUp, got", err)
+	}
+	approval, _ = O.SignChallenge(c, requestUrl, signer)
+	if _, _, err := O.VerifyStepUp(approval, requestUrl, clientIp, session, "delete repo octokey/octokey-go"); err != ErrStepUpOperationMismatch {
+		t.Error("expected ErrStepUpOperationMismatch, got", err)
+	}
+
+	c, _ = O.NewStepUpChallenge(clientIp, "delete repo octokey/octokey-go")
+	approval, _ = O.SignChallenge(c, requestUrl, signer)
+
+	O.ChallengeStore = nil
+	if _, err := O.NewStepUpChallenge(clientIp, "delete repo octokey/octokey-go"); err != ErrStepUpNoChallengeStore {
+		t.Error("expected ErrStepUpNoChallengeStore, got", err)
+	}
+	if _, _, err := O.VerifyStepUp(approval, requestUrl, clientIp, session, "delete repo octokey/octokey-go"); err != ErrStepUpNoChallengeStore {
+		t.Error("expected ErrStepUpNoChallengeStore, got", err)
+	}
+}